@@ -0,0 +1,26 @@
+package memorypack
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// SerializeToBase64 serializes a value and encodes the result as unpadded
+// URL-safe base64, for embedding in JSON documents or URLs.
+func SerializeToBase64(value any) (string, error) {
+	data, err := Serialize(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DeserializeFromBase64 decodes a string produced by SerializeToBase64 and
+// deserializes the result.
+func DeserializeFromBase64[T any](s string, value T) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid base64: %w", err)
+	}
+	return Deserialize(data, value)
+}
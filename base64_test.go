@@ -0,0 +1,54 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestBase64 tests round-tripping values through URL-safe base64.
+func TestBase64(t *testing.T) {
+	type Token struct {
+		User  string
+		Scope []string
+		Exp   int64
+		Nonce []byte
+	}
+	original := Token{User: "yuuka", Scope: []string{"read", "write"}, Exp: -1, Nonce: []byte{0xFB, 0xFF, 0xFE}}
+
+	s, err := memorypack.SerializeToBase64(&original)
+	if err != nil {
+		t.Fatalf("SerializeToBase64 failed: %v", err)
+	}
+	if strings.ContainsAny(s, "+/=") {
+		t.Errorf("Expected URL-safe base64 without padding, got %q", s)
+	}
+
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("DecodeString failed: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("Base64 decodes to %x, want %x", decoded, data)
+	}
+
+	var result Token
+	if err = memorypack.DeserializeFromBase64(s, &result); err != nil {
+		t.Fatalf("DeserializeFromBase64 failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf("Got %+v, want %+v", result, original)
+	}
+
+	if err = memorypack.DeserializeFromBase64("not base64!", &result); err == nil {
+		t.Error("Expected error for invalid base64, got nil")
+	}
+}
@@ -0,0 +1,85 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// packedBoolsHeader returns the collection header of a bit-packed []bool of
+// length n. Packed headers are below NullCollection so that they cannot be
+// confused with a plain length.
+func packedBoolsHeader(n int) int32 {
+	return NullCollection - 1 - int32(n)
+}
+
+// writePackedBools writes a non-nil []bool as a bitset, least significant bit first.
+func writePackedBools(writer *Writer, v reflect.Value) error {
+	n := v.Len()
+	// Packed headers are offset by one from NullCollection, leaving one less length.
+	if n >= maxCollectionLength {
+		return fmt.Errorf("packed bool slice length %d exceeds the maximum of %d", n, maxCollectionLength-1)
+	}
+	writer.WriteInt32(packedBoolsHeader(n))
+
+	size := (n + 7) / 8
+	writer.ensureCapacity(size)
+	bits := writer.buffer[writer.pos : writer.pos+size]
+	clear(bits)
+	for i := range n {
+		if v.Index(i).Bool() {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	writer.pos += size
+	return nil
+}
+
+// readBoolSlice reads a []bool written either as a plain collection or by
+// writePackedBools.
+func readBoolSlice(reader *Reader, v reflect.Value) error {
+	header, err := reader.ReadInt32()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case header == NullCollection:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case header < NullCollection:
+		n := int(packedBoolsHeader(0) - header)
+		size := (n + 7) / 8
+		if !reader.ensure(size) {
+			return fmt.Errorf("read error: requested %d bytes for packed bools but only %d bytes available: %w",
+				size, len(reader.buffer)-reader.pos, ErrTruncated)
+		}
+		bits := reader.buffer[reader.pos : reader.pos+size]
+		reader.pos += size
+
+		if err = reader.allocate(n, 1); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(v.Type(), n, n)
+		for i := range n {
+			slice.Index(i).SetBool(bits[i/8]&(1<<(i%8)) != 0)
+		}
+		v.Set(slice)
+		return nil
+	default:
+		n := int(header)
+		if err = reader.checkLength(n, v.Type().Elem()); err != nil {
+			return err
+		}
+		if err = reader.allocate(n, 1); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(v.Type(), n, n)
+		for i := range n {
+			if err = readValue(reader, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+}
@@ -0,0 +1,36 @@
+package memorypack
+
+import "fmt"
+
+// allocate charges count values of size bytes against Options.MaxAllocBytes,
+// failing once the total for the decode would exceed it.
+func (r *Reader) allocate(count int, size uintptr) error {
+	limit := r.options.MaxAllocBytes
+	if limit <= 0 || count <= 0 {
+		return nil
+	}
+	if r.allocated == nil {
+		r.allocated = new(int64)
+	}
+
+	left := limit - *r.allocated
+	if size > 0 && int64(count) > left/int64(size) {
+		return fmt.Errorf("allocation budget exceeded: %d values of %d bytes with %d of %d bytes left",
+			count, size, left, limit)
+	}
+	*r.allocated += int64(count) * int64(size)
+	return nil
+}
+
+// budgetLeft returns the bytes left in the allocation budget, and false if
+// there is no budget.
+func (r *Reader) budgetLeft() (int64, bool) {
+	limit := r.options.MaxAllocBytes
+	if limit <= 0 {
+		return 0, false
+	}
+	if r.allocated == nil {
+		return limit, true
+	}
+	return limit - *r.allocated, true
+}
@@ -0,0 +1,72 @@
+package memorypack
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// sortedMapKeys returns the keys of map v in the order CanonicalMaps writes
+// them: integers, floats and times numerically, strings lexically, false
+// before true.
+func sortedMapKeys(v reflect.Value) ([]reflect.Value, error) {
+	keys := v.MapKeys()
+
+	var compare func(a, b reflect.Value) int
+	switch kind := v.Type().Key().Kind(); {
+	case v.Type().Key() == timeType:
+		compare = func(a, b reflect.Value) int { return a.Interface().(time.Time).Compare(b.Interface().(time.Time)) }
+	case isSignedInt(kind):
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.Int(), b.Int()) }
+	case isUnsignedInt(kind), kind == reflect.Uintptr:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.Uint(), b.Uint()) }
+	case kind == reflect.Float32, kind == reflect.Float64:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.Float(), b.Float()) }
+	case kind == reflect.String:
+		compare = func(a, b reflect.Value) int { return cmp.Compare(a.String(), b.String()) }
+	case kind == reflect.Bool:
+		compare = func(a, b reflect.Value) int {
+			switch {
+			case a.Bool() == b.Bool():
+				return 0
+			case b.Bool():
+				return -1
+			default:
+				return 1
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cannot order map keys of type %s canonically", v.Type().Key())
+	}
+
+	slices.SortFunc(keys, compare)
+	return keys, nil
+}
+
+// SerializeSortedSlice serializes a sorted copy of s, in the same format as a
+// []T, so that set-like data always encodes to the same bytes regardless of
+// the order it was collected in. s itself is left unchanged.
+func SerializeSortedSlice[T cmp.Ordered](s []T) ([]byte, error) {
+	sorted := slices.Clone(s)
+	slices.Sort(sorted)
+	return Serialize(&sorted)
+}
+
+// DeserializeSortedSlice deserializes a []T written by SerializeSortedSlice.
+// If verify is set, it fails unless the elements are in ascending order.
+func DeserializeSortedSlice[T cmp.Ordered](data []byte, verify bool) ([]T, error) {
+	var s []T
+	if err := Deserialize(data, &s); err != nil {
+		return nil, err
+	}
+	if verify {
+		for i := 1; i < len(s); i++ {
+			if cmp.Less(s[i], s[i-1]) {
+				return nil, fmt.Errorf("slice is not sorted: element %d (%v) is less than element %d (%v)", i, s[i], i-1, s[i-1])
+			}
+		}
+	}
+	return s, nil
+}
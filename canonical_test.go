@@ -0,0 +1,65 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestSortedSlice tests that sorted slices encode deterministically.
+func TestSortedSlice(t *testing.T) {
+	orders := [][]string{
+		{"pear", "apple", "fig", "kiwi"},
+		{"kiwi", "fig", "pear", "apple"},
+		{"apple", "fig", "kiwi", "pear"},
+	}
+	first, err := memorypack.SerializeSortedSlice(orders[0])
+	if err != nil {
+		t.Fatalf("SerializeSortedSlice failed: %v", err)
+	}
+	for _, order := range orders[1:] {
+		data, err := memorypack.SerializeSortedSlice(order)
+		if err != nil {
+			t.Fatalf("SerializeSortedSlice failed: %v", err)
+		}
+		if !bytes.Equal(data, first) {
+			t.Errorf("Expected the same bytes for %q", order)
+		}
+	}
+	if orders[0][0] != "pear" {
+		t.Errorf("Expected the input to be left unchanged, got %q", orders[0])
+	}
+
+	result, err := memorypack.DeserializeSortedSlice[string](first, true)
+	if err != nil {
+		t.Fatalf("DeserializeSortedSlice failed: %v", err)
+	}
+	if want := []string{"apple", "fig", "kiwi", "pear"}; !slices.Equal(result, want) {
+		t.Errorf("Got %q, want %q", result, want)
+	}
+
+	t.Run("Verify", func(t *testing.T) {
+		unsorted, err := memorypack.Serialize(&[]int32{3, 1, 2})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if _, err = memorypack.DeserializeSortedSlice[int32](unsorted, true); err == nil {
+			t.Error("Expected error for an unsorted slice")
+		}
+		if s, err := memorypack.DeserializeSortedSlice[int32](unsorted, false); err != nil || len(s) != 3 {
+			t.Errorf("Expected the slice without verification, got %v, err: %v", s, err)
+		}
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		data, err := memorypack.SerializeSortedSlice[float64](nil)
+		if err != nil {
+			t.Fatalf("SerializeSortedSlice failed: %v", err)
+		}
+		if s, err := memorypack.DeserializeSortedSlice[float64](data, true); err != nil || s != nil {
+			t.Errorf("Expected nil, got %v, err: %v", s, err)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package memorypack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Checksum computes an integrity check over serialized data.
+type Checksum interface {
+	// Size returns the length of the checksum in bytes.
+	Size() int
+	// Append appends the checksum of data to dst and returns the result.
+	Append(dst, data []byte) []byte
+}
+
+// CRC32 is a Checksum using the IEEE CRC-32 polynomial, stored little-endian.
+var CRC32 Checksum = crc32Checksum{table: crc32.IEEETable}
+
+type crc32Checksum struct {
+	table *crc32.Table
+}
+
+func (c crc32Checksum) Size() int {
+	return crc32.Size
+}
+
+func (c crc32Checksum) Append(dst, data []byte) []byte {
+	return binary.LittleEndian.AppendUint32(dst, crc32.Checksum(data, c.table))
+}
+
+// SerializeWithChecksum serializes a value and appends a checksum of the
+// result. A nil sum uses CRC32.
+func SerializeWithChecksum(value any, sum Checksum) ([]byte, error) {
+	if sum == nil {
+		sum = CRC32
+	}
+	data, err := Serialize(value)
+	if err != nil {
+		return nil, err
+	}
+	return sum.Append(data, data), nil
+}
+
+// DeserializeWithChecksum verifies the checksum appended by
+// SerializeWithChecksum and then deserializes the payload. A nil sum uses CRC32.
+func DeserializeWithChecksum[T any](data []byte, value T, sum Checksum) error {
+	if sum == nil {
+		sum = CRC32
+	}
+	n := len(data) - sum.Size()
+	if n < 0 {
+		return fmt.Errorf("data too short for a %d-byte checksum: %w", sum.Size(), ErrTruncated)
+	}
+
+	payload := data[:n]
+	if !bytes.Equal(sum.Append(nil, payload), data[n:]) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return Deserialize(payload, value)
+}
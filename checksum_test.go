@@ -0,0 +1,56 @@
+package memorypack_test
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// sha256Checksum is a Checksum using a truncated SHA-256.
+type sha256Checksum struct{}
+
+func (sha256Checksum) Size() int { return 8 }
+
+func (sha256Checksum) Append(dst, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return append(dst, sum[:8]...)
+}
+
+// TestChecksum tests detecting corrupted payloads.
+func TestChecksum(t *testing.T) {
+	type Record struct {
+		ID    int64
+		Label string
+	}
+	original := Record{ID: 12, Label: "twelve"}
+
+	for _, sum := range []memorypack.Checksum{nil, memorypack.CRC32, sha256Checksum{}} {
+		data, err := memorypack.SerializeWithChecksum(&original, sum)
+		if err != nil {
+			t.Fatalf("SerializeWithChecksum failed: %v", err)
+		}
+
+		var result Record
+		if err = memorypack.DeserializeWithChecksum(data, &result, sum); err != nil {
+			t.Fatalf("DeserializeWithChecksum failed: %v", err)
+		}
+		if !reflect.DeepEqual(result, original) {
+			t.Errorf("Got %+v, want %+v", result, original)
+		}
+
+		for i := range data {
+			corrupt := append([]byte(nil), data...)
+			corrupt[i] ^= 0x01
+			if err = memorypack.DeserializeWithChecksum(corrupt, &result, sum); err == nil || err.Error() != "checksum mismatch" {
+				t.Errorf("Corrupting byte %d: got error %v, want checksum mismatch", i, err)
+			}
+		}
+	}
+
+	var result Record
+	if err := memorypack.DeserializeWithChecksum([]byte{1, 2}, &result, nil); err == nil {
+		t.Error("Expected error for data shorter than the checksum, got nil")
+	}
+}
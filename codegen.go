@@ -0,0 +1,198 @@
+package memorypack
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// modulePath is the import path generated formatters use for this package.
+const modulePath = "github.com/arisu-archive/memorypack-go"
+
+// GenerateFormatter returns the source of a Go file declaring Serialize and
+// Deserialize methods for the struct type t, so that it implements Formatter
+// without reflection. The file belongs in the package that declares t.
+//
+// The generated methods write the same bytes as Serialize with the default
+// options. Fields of primitive kinds are written directly; other fields use
+// WriteValue and ReadValue. Registered enums, flag masks, compressed or
+// encrypted fields and types implementing TransientFields are not supported.
+func GenerateFormatter(t reflect.Type) (string, error) {
+	if t == nil || t.Kind() != reflect.Struct || t.Name() == "" {
+		return "", fmt.Errorf("cannot generate a formatter for %v: not a named struct type", t)
+	}
+	if strings.Contains(t.Name(), "[") {
+		return "", fmt.Errorf("cannot generate a formatter for generic type %s", t)
+	}
+	if reflect.PointerTo(t).Implements(transientFieldsType) {
+		return "", fmt.Errorf("cannot generate a formatter for %s: its transient fields are chosen at runtime", t)
+	}
+
+	g := &generator{pkgPath: t.PkgPath(), imports: map[string]bool{"fmt": true, modulePath: true}}
+	fields := getFormatterData(t).fields
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// Serialize implements memorypack.Formatter.\n")
+	fmt.Fprintf(&body, "func (v *%s) Serialize(writer *memorypack.Writer) error {\n", t.Name())
+	fmt.Fprintf(&body, "if err := writer.WriteObjectHeader(%d); err != nil {\nreturn err\n}\n", len(fields))
+	for _, field := range fields {
+		if err := checkGeneratedField(t, field); err != nil {
+			return "", err
+		}
+		g.writeField(&body, t.Field(field.index))
+	}
+	fmt.Fprintf(&body, "return nil\n}\n\n")
+
+	fmt.Fprintf(&body, "// Deserialize implements memorypack.Formatter.\n")
+	fmt.Fprintf(&body, "func (v *%s) Deserialize(reader *memorypack.Reader) error {\n", t.Name())
+	fmt.Fprintf(&body, "count, isNull, err := reader.ReadObjectHeader()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(&body, "if isNull {\n*v = %s{}\nreturn nil\n}\n", t.Name())
+	fmt.Fprintf(&body, "if count != %d {\nreturn fmt.Errorf(\"%s: %%w: expected %d fields, got %%d\", memorypack.ErrFieldCountMismatch, count)\n}\n",
+		len(fields), t.Name(), len(fields))
+	for _, field := range fields {
+		g.readField(&body, t.Field(field.index))
+	}
+	fmt.Fprintf(&body, "return nil\n}\n")
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by memorypack.GenerateFormatter. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", packageName(t))
+	fmt.Fprintf(&src, "import (\n")
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	// Standard library imports come first, then a separate group for modules.
+	isModule := func(path string) bool {
+		first, _, _ := strings.Cut(path, "/")
+		return strings.Contains(first, ".")
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if mi, mj := isModule(paths[i]), isModule(paths[j]); mi != mj {
+			return mj
+		}
+		return paths[i] < paths[j]
+	})
+	for i, path := range paths {
+		if i > 0 && isModule(path) && !isModule(paths[i-1]) {
+			src.WriteString("\n")
+		}
+		fmt.Fprintf(&src, "%q\n", path)
+	}
+	fmt.Fprintf(&src, ")\n\n")
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("generated invalid source for %s: %w", t, err)
+	}
+	return string(formatted), nil
+}
+
+// generator accumulates the imports of a generated formatter.
+type generator struct {
+	pkgPath string
+	imports map[string]bool
+}
+
+// checkGeneratedField rejects fields whose encoding generated code cannot
+// reproduce.
+func checkGeneratedField(t reflect.Type, field fieldInfo) error {
+	ft := t.Field(field.index).Type
+	switch {
+	case field.compress:
+		return fmt.Errorf("field %s of %s is compressed, which generated formatters do not support", field.name, t)
+	case field.encrypt:
+		return fmt.Errorf("field %s of %s is encrypted, which generated formatters do not support", field.name, t)
+	case isEnumType(ft):
+		return fmt.Errorf("field %s of %s is a registered enum, which generated formatters do not support", field.name, t)
+	case hasFlagMask(ft):
+		return fmt.Errorf("field %s of %s has a registered flag mask, which generated formatters do not support", field.name, t)
+	}
+	return nil
+}
+
+// primitiveMethods returns the Writer and Reader method suffix and wire type
+// used to encode kind directly, or "" for kinds written with WriteValue.
+func primitiveMethods(kind reflect.Kind) (method, wire string) {
+	switch kind {
+	case reflect.Bool:
+		return "Bool", "bool"
+	case reflect.Int8, reflect.Uint8:
+		return "Byte", "byte"
+	case reflect.Int16, reflect.Uint16:
+		return "Int16", "int16"
+	case reflect.Int32, reflect.Uint32:
+		return "Int32", "int32"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return "Int64", "int64"
+	case reflect.Float32:
+		return "Float32", "float32"
+	case reflect.Float64:
+		return "Float64", "float64"
+	case reflect.String:
+		return "String", "string"
+	default:
+		return "", ""
+	}
+}
+
+// direct reports whether a field of type t is written with a primitive method.
+func direct(t reflect.Type) bool {
+	method, _ := primitiveMethods(t.Kind())
+	return method != "" && !isKnownType(t) && !isFormatterType(t)
+}
+
+// writeField emits the code writing one field.
+func (g *generator) writeField(buf *bytes.Buffer, field reflect.StructField) {
+	if !direct(field.Type) {
+		fmt.Fprintf(buf, "if err := memorypack.WriteValue(writer, v.%s); err != nil {\nreturn err\n}\n", field.Name)
+		return
+	}
+	method, wire := primitiveMethods(field.Type.Kind())
+	fmt.Fprintf(buf, "writer.Write%s(%s(v.%s))\n", method, wire, field.Name)
+}
+
+// readField emits the code reading one field.
+func (g *generator) readField(buf *bytes.Buffer, field reflect.StructField) {
+	typ := g.typeExpr(field.Type)
+	if !direct(field.Type) {
+		fmt.Fprintf(buf, "if v.%s, err = memorypack.ReadValue[%s](reader); err != nil {\nreturn err\n}\n", field.Name, typ)
+		return
+	}
+	method, _ := primitiveMethods(field.Type.Kind())
+	fmt.Fprintf(buf, "{\nx, err := reader.Read%s()\nif err != nil {\nreturn err\n}\nv.%s = %s(x)\n}\n", method, field.Name, typ)
+}
+
+// typeExpr returns the Go expression for t in the generated file, adding the
+// imports it needs.
+func (g *generator) typeExpr(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" || t.PkgPath() == g.pkgPath {
+			return t.Name()
+		}
+		g.imports[t.PkgPath()] = true
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + g.typeExpr(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeExpr(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeExpr(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", g.typeExpr(t.Key()), g.typeExpr(t.Elem()))
+	default:
+		return t.String()
+	}
+}
+
+// packageName returns the name of the package declaring the named type t.
+func packageName(t reflect.Type) string {
+	name, _, _ := strings.Cut(t.String(), ".")
+	return name
+}
@@ -0,0 +1,180 @@
+package memorypack_test
+
+import (
+	"encoding/hex"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type genLevel int16
+
+// genRecord must match genRecordSource, which declares it in a generated program.
+type genRecord struct {
+	ID     int64
+	Small  uint8
+	Flag   bool
+	Score  float32
+	Name   string
+	Level  genLevel
+	Count  uint32
+	Tags   []string
+	Counts map[string]int64
+	Next   *genRecord
+	Skip   string `memorypack:"-"`
+}
+
+const genRecordSource = `
+type genLevel int16
+
+type genRecord struct {
+	ID     int64
+	Small  uint8
+	Flag   bool
+	Score  float32
+	Name   string
+	Level  genLevel
+	Count  uint32
+	Tags   []string
+	Counts map[string]int64
+	Next   *genRecord
+	Skip   string ` + "`memorypack:\"-\"`" + `
+}
+`
+
+var genRecordValue = genRecord{
+	ID: -5, Small: 200, Flag: true, Score: 1.5, Name: "gen", Level: -3, Count: 4_000_000_000,
+	Tags: []string{"a", "b"}, Counts: map[string]int64{"k": 9}, Next: &genRecord{Name: "next"},
+}
+
+// TestGenerateFormatter tests generating a reflection-free Formatter.
+func TestGenerateFormatter(t *testing.T) {
+	src, err := memorypack.GenerateFormatter(reflect.TypeFor[genRecord]())
+	if err != nil {
+		t.Fatalf("GenerateFormatter failed: %v", err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("Generated source does not parse: %v\n%s", err, src)
+	}
+	if file.Name.Name != "memorypack_test" {
+		t.Errorf("Expected package memorypack_test, got %s", file.Name.Name)
+	}
+	for _, want := range []string{
+		"func (v *genRecord) Serialize(writer *memorypack.Writer) error",
+		"func (v *genRecord) Deserialize(reader *memorypack.Reader) error",
+		"v.Level = genLevel(x)",
+		"memorypack.ReadValue[map[string]int64](reader)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Generated source lacks %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "Skip") {
+		t.Error("Generated source encodes an ignored field")
+	}
+
+	if _, err = memorypack.GenerateFormatter(reflect.TypeFor[[]int]()); err == nil {
+		t.Error("Expected error for a non-struct type")
+	}
+
+	t.Run("FlagMask", func(t *testing.T) {
+		type genFlags uint8
+		type genFlagged struct {
+			Flags genFlags
+		}
+		if err := memorypack.RegisterFlagMask(reflect.TypeFor[genFlags](), 0x3); err != nil {
+			t.Fatalf("RegisterFlagMask failed: %v", err)
+		}
+		if _, err := memorypack.GenerateFormatter(reflect.TypeFor[genFlagged]()); err == nil || !strings.Contains(err.Error(), "flag mask") {
+			t.Errorf("Expected error for a field with a flag mask, got %v", err)
+		}
+	})
+
+	t.Run("Transient", func(t *testing.T) {
+		if _, err := memorypack.GenerateFormatter(reflect.TypeFor[transientConfig]()); err == nil || !strings.Contains(err.Error(), "transient") {
+			t.Errorf("Expected error for a type with transient fields, got %v", err)
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("builds a program")
+		}
+		goTool, err := exec.LookPath("go")
+		if err != nil {
+			t.Skip("go tool not found")
+		}
+		repo, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := memorypack.Serialize(&genRecordValue)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		dir := t.TempDir()
+		files := map[string]string{
+			"go.mod": "module gentest\n\ngo 1.23\n\nrequire github.com/arisu-archive/memorypack-go v0.0.0\n\n" +
+				"replace github.com/arisu-archive/memorypack-go => " + repo + "\n",
+			"gen.go":  strings.Replace(src, "package memorypack_test", "package main", 1),
+			"main.go": genMainSource,
+		}
+		for name, content := range files {
+			if err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cmd := exec.Command(goTool, "run", ".", hex.EncodeToString(want))
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+		out, err := cmd.CombinedOutput()
+		if err != nil || strings.TrimSpace(string(out)) != "ok" {
+			t.Errorf("Generated formatter failed: %v\n%s", err, out)
+		}
+	})
+}
+
+// genMainSource checks that the generated formatter writes the bytes given as
+// its argument and reads them back.
+const genMainSource = `package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+` + genRecordSource + `
+func main() {
+	value := genRecord{
+		ID: -5, Small: 200, Flag: true, Score: 1.5, Name: "gen", Level: -3, Count: 4_000_000_000,
+		Tags: []string{"a", "b"}, Counts: map[string]int64{"k": 9}, Next: &genRecord{Name: "next"},
+	}
+	want, _ := hex.DecodeString(os.Args[1])
+	data, err := memorypack.Serialize(&value)
+	if err != nil || !bytes.Equal(data, want) {
+		fmt.Printf("serialize: %v\ngot  %x\nwant %x\n", err, data, want)
+		os.Exit(1)
+	}
+	var result genRecord
+	if err = memorypack.Deserialize(data, &result); err != nil || !reflect.DeepEqual(result, value) {
+		fmt.Printf("deserialize: %v\n%+v\n", err, result)
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+`
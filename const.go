@@ -9,14 +9,15 @@ const (
 	NullCollection int32 = -1 // 0xFFFFFFFF
 
 	// Object header constants.
-	WideTag     byte = 250 // For Union, wide tag
-	ReferenceID byte = 250 // For circular references
-	Reserved1   byte = 250
-	Reserved2   byte = 251
-	Reserved3   byte = 252
-	Reserved4   byte = 253
-	Reserved5   byte = 254
-	NullObject  byte = 255 // 0xFF
+	WideTag      byte = 250 // For Union, wide tag
+	ReferenceID  byte = 250 // For circular references
+	ReferenceNew byte = 251 // First occurrence of a tracked reference
+	Reserved1    byte = 250
+	Reserved2    byte = 251
+	Reserved3    byte = 252
+	Reserved4    byte = 253
+	Reserved5    byte = 254
+	NullObject   byte = 255 // 0xFF
 
 	// Depth constants.
 	MaxDepth = 1000
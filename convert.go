@@ -0,0 +1,284 @@
+package memorypack
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+var anyType = reflect.TypeFor[any]()
+
+// ToMap converts a struct into a map of field name to value, using the same
+// fields that serialization would write.
+//
+// Nested structs, including those behind pointers, interfaces and in slices,
+// arrays and maps, are converted recursively. Other values are copied as is.
+// It is meant for logging and debugging.
+func ToMap(value any) (map[string]any, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ToMap requires a struct, got %T", value)
+	}
+
+	m, err := toGeneric(v, 0)
+	if err != nil {
+		return nil, err
+	}
+	return m.(map[string]any), nil
+}
+
+// toGeneric converts v into a value with structs replaced by maps.
+func toGeneric(v reflect.Value, depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("conversion %w %d, possible circular reference detected", ErrDepthExceeded, MaxDepth)
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if isKnownType(v.Type()) || isFormatterType(v.Type()) || !containsStruct(v.Type()) {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fd := getFormatterData(v.Type())
+		m := make(map[string]any, len(fd.fields))
+		for _, field := range fd.fields {
+			value, err := toGeneric(v.Field(field.index), depth+1)
+			if err != nil {
+				return nil, withField(err, field.name)
+			}
+			m[field.name] = value
+		}
+		return m, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toGeneric(v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []any(nil), nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			value, err := toGeneric(v.Index(i), depth+1)
+			if err != nil {
+				return nil, withIndex(err, i)
+			}
+			list[i] = value
+		}
+		return list, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(reflect.MapOf(v.Type().Key(), anyType)).Interface(), nil
+		}
+		m := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), anyType), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value, err := toGeneric(iter.Value(), depth+1)
+			if err != nil {
+				return nil, withKey(err, iter.Key())
+			}
+			m.SetMapIndex(iter.Key(), reflect.ValueOf(&value).Elem())
+		}
+		return m.Interface(), nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// containsStruct reports whether values of t may hold a struct that ToMap
+// converts. Interfaces may hold anything.
+func containsStruct(t reflect.Type) bool {
+	var seen []reflect.Type
+	for {
+		switch t.Kind() {
+		case reflect.Struct, reflect.Interface:
+			return true
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			// Types such as "type List []List" contain themselves.
+			if slices.Contains(seen, t) {
+				return false
+			}
+			seen = append(seen, t)
+			t = t.Elem()
+			if isKnownType(t) || isFormatterType(t) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// FromMap populates the struct that out points to from a map of field name to
+// value, such as one produced by ToMap.
+//
+// Values are converted where this cannot lose information: between integer
+// types when the value fits, from integers and floats to floats when the value
+// is represented exactly, between
+// named types of the same kind, and element-wise for slices and maps. Nested
+// maps populate nested structs. Fields missing from m are left unchanged.
+func FromMap(m map[string]any, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("FromMap requires a non-nil pointer to a struct")
+	}
+	return structFromMap(v.Elem(), m, 0)
+}
+
+// structFromMap assigns the entries of m to the fields of the struct v.
+func structFromMap(v reflect.Value, m map[string]any, depth int) error {
+	fd := getFormatterData(v.Type())
+	for name, value := range m {
+		i := slices.IndexFunc(fd.fields, func(field fieldInfo) bool { return field.name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown field %q in %s", name, v.Type())
+		}
+		if err := fromGeneric(v.Field(fd.fields[i].index), value, depth+1); err != nil {
+			return withField(err, name)
+		}
+	}
+	return nil
+}
+
+// fromGeneric assigns value to dst, converting it where this is safe.
+func fromGeneric(dst reflect.Value, value any, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("conversion %w %d, possible circular reference detected", ErrDepthExceeded, MaxDepth)
+	}
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	src := reflect.ValueOf(value)
+	t := dst.Type()
+	if src.Type().AssignableTo(t) {
+		dst.Set(src)
+		return nil
+	}
+
+	switch kind := t.Kind(); {
+	case kind == reflect.Struct:
+		if m, ok := value.(map[string]any); ok {
+			return structFromMap(dst, m, depth)
+		}
+	case kind == reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := fromGeneric(elem.Elem(), value, depth+1); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case isSignedInt(kind):
+		if i, ok := asInt64(src); ok && !dst.OverflowInt(i) {
+			dst.SetInt(i)
+			return nil
+		}
+	case isUnsignedInt(kind):
+		if u, ok := asUint64(src); ok && !dst.OverflowUint(u) {
+			dst.SetUint(u)
+			return nil
+		}
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		if f, ok := asExactFloat(src, kind); ok {
+			dst.SetFloat(f)
+			return nil
+		}
+	case kind == reflect.Slice && (src.Kind() == reflect.Slice || src.Kind() == reflect.Array):
+		if src.Kind() == reflect.Slice && src.IsNil() {
+			dst.Set(reflect.Zero(t))
+			return nil
+		}
+		slice := reflect.MakeSlice(t, src.Len(), src.Len())
+		for i := range src.Len() {
+			if err := fromGeneric(slice.Index(i), src.Index(i).Interface(), depth+1); err != nil {
+				return withIndex(err, i)
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case kind == reflect.Map && src.Kind() == reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(t))
+			return nil
+		}
+		m := reflect.MakeMapWithSize(t, src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			key := reflect.New(t.Key()).Elem()
+			if err := fromGeneric(key, iter.Key().Interface(), depth+1); err != nil {
+				return withKey(err, iter.Key())
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err := fromGeneric(elem, iter.Value().Interface(), depth+1); err != nil {
+				return withKey(err, iter.Key())
+			}
+			m.SetMapIndex(key, elem)
+		}
+		dst.Set(m)
+		return nil
+	case kind == src.Kind() && src.Type().ConvertibleTo(t):
+		// Named types sharing an underlying kind, such as string and a string enum.
+		dst.Set(src.Convert(t))
+		return nil
+	}
+	return fmt.Errorf("cannot convert %T to %s", value, t)
+}
+
+// asInt64 returns the value of an integer of any kind as an int64, if it fits.
+func asInt64(v reflect.Value) (int64, bool) {
+	switch {
+	case isSignedInt(v.Kind()):
+		return v.Int(), true
+	case isUnsignedInt(v.Kind()) && v.Uint() <= math.MaxInt64:
+		return int64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+// asExactFloat returns the value of an integer or float as a float of the
+// given kind, if it is represented exactly.
+func asExactFloat(v reflect.Value, kind reflect.Kind) (float64, bool) {
+	round := func(f float64) float64 {
+		if kind == reflect.Float32 {
+			return float64(float32(f))
+		}
+		return f
+	}
+	switch {
+	case isSignedInt(v.Kind()):
+		i := v.Int()
+		f := round(float64(i))
+		return f, f >= math.MinInt64 && f < math.MaxInt64 && int64(f) == i
+	case isUnsignedInt(v.Kind()):
+		u := v.Uint()
+		f := round(float64(u))
+		return f, f < math.MaxUint64 && uint64(f) == u
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		f := v.Float()
+		return round(f), round(f) == f || math.IsNaN(f)
+	default:
+		return 0, false
+	}
+}
+
+// asUint64 returns the value of an integer of any kind as a uint64, if it is
+// not negative.
+func asUint64(v reflect.Value) (uint64, bool) {
+	switch {
+	case isUnsignedInt(v.Kind()):
+		return v.Uint(), true
+	case isSignedInt(v.Kind()) && v.Int() >= 0:
+		return uint64(v.Int()), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,139 @@
+package memorypack_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type convertAddress struct {
+	City string
+	Zip  string
+}
+
+type convertPerson struct {
+	Name     string
+	Age      int32
+	Tags     []string
+	Home     convertAddress
+	Work     *convertAddress
+	Previous []convertAddress
+	Scores   map[string]float64
+	secret   string
+}
+
+// TestToMap tests converting a struct into a generic map.
+func TestToMap(t *testing.T) {
+	person := convertPerson{
+		Name:     "Ann",
+		Age:      41,
+		Tags:     []string{"a", "b"},
+		Home:     convertAddress{City: "Oslo", Zip: "0150"},
+		Previous: []convertAddress{{City: "Bergen"}},
+		Scores:   map[string]float64{"go": 9.5},
+		secret:   "hidden",
+	}
+
+	got, err := memorypack.ToMap(&person)
+	if err != nil {
+		t.Fatalf("ToMap failed: %v", err)
+	}
+
+	want := map[string]any{
+		"Name":     "Ann",
+		"Age":      int32(41),
+		"Tags":     []string{"a", "b"},
+		"Home":     map[string]any{"City": "Oslo", "Zip": "0150"},
+		"Work":     nil,
+		"Previous": []any{map[string]any{"City": "Bergen", "Zip": ""}},
+		"Scores":   map[string]float64{"go": 9.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %#v, want %#v", got, want)
+	}
+
+	if _, err = memorypack.ToMap(42); err == nil {
+		t.Error("Expected error for a non-struct value, got nil")
+	}
+}
+
+// TestFromMap tests populating a struct from a generic map.
+func TestFromMap(t *testing.T) {
+	var person convertPerson
+	err := memorypack.FromMap(map[string]any{
+		"Name":     "Bo",
+		"Age":      27, // int to int32
+		"Tags":     []any{"x", "y"},
+		"Home":     map[string]any{"City": "Rome"},
+		"Work":     map[string]any{"City": "Milan", "Zip": "20121"},
+		"Previous": []any{map[string]any{"City": "Pisa"}},
+		"Scores":   map[string]any{"go": 8, "rust": 7.5},
+	}, &person)
+	if err != nil {
+		t.Fatalf("FromMap failed: %v", err)
+	}
+
+	want := convertPerson{
+		Name:     "Bo",
+		Age:      27,
+		Tags:     []string{"x", "y"},
+		Home:     convertAddress{City: "Rome"},
+		Work:     &convertAddress{City: "Milan", Zip: "20121"},
+		Previous: []convertAddress{{City: "Pisa"}},
+		Scores:   map[string]float64{"go": 8, "rust": 7.5},
+	}
+	if !reflect.DeepEqual(person, want) {
+		t.Errorf("Got %+v, want %+v", person, want)
+	}
+
+	t.Run("ToMapRoundTrip", func(t *testing.T) {
+		m, err := memorypack.ToMap(want)
+		if err != nil {
+			t.Fatalf("ToMap failed: %v", err)
+		}
+		var result convertPerson
+		if err = memorypack.FromMap(m, &result); err != nil {
+			t.Fatalf("FromMap failed: %v", err)
+		}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	for _, tc := range []struct {
+		m    map[string]any
+		want string
+	}{
+		{map[string]any{"Age": "old"}, "Age: cannot convert string to int32"},
+		{map[string]any{"Age": int64(1) << 40}, "Age: cannot convert int64 to int32"},
+		{map[string]any{"Tags": []any{"ok", 3}}, "Tags[1]: cannot convert int to string"},
+		{map[string]any{"Home": map[string]any{"Zip": 1}}, "Home.Zip: cannot convert int to string"},
+		{map[string]any{"Email": "x"}, `unknown field "Email" in memorypack_test.convertPerson`},
+		{map[string]any{"Scores": map[string]any{"go": int64(1)<<53 + 1}}, `Scores["go"]: cannot convert int64 to float64`},
+	} {
+		var result convertPerson
+		if err := memorypack.FromMap(tc.m, &result); err == nil || err.Error() != tc.want {
+			t.Errorf("FromMap(%v): got error %v, want %q", tc.m, err, tc.want)
+		}
+	}
+
+	t.Run("Float32", func(t *testing.T) {
+		type Reading struct {
+			Value float32
+		}
+		for _, value := range []any{0.5, 1 << 24, float32(0.1), math.Inf(-1)} {
+			var result Reading
+			if err := memorypack.FromMap(map[string]any{"Value": value}, &result); err != nil {
+				t.Errorf("FromMap(%v) failed: %v", value, err)
+			}
+		}
+		for _, value := range []any{0.1, 1<<24 + 1, uint64(math.MaxUint64), 1e300} {
+			var result Reading
+			if err := memorypack.FromMap(map[string]any{"Value": value}, &result); err == nil {
+				t.Errorf("FromMap(%v): expected an error for a value float32 cannot represent, got %v", value, result.Value)
+			}
+		}
+	})
+}
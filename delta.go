@@ -0,0 +1,61 @@
+package memorypack
+
+import "reflect"
+
+// isDeltaSlice reports whether a slice of t is delta encoded with opts.
+func isDeltaSlice(t reflect.Type, opts *Options) bool {
+	if !opts.DeltaInts {
+		return false
+	}
+	elem := t.Elem()
+	return (elem.Kind() == reflect.Int || elem.Kind() == reflect.Int64) && !isEnumType(elem)
+}
+
+// writeDeltaInts writes a non-nil integer slice as its first value followed by
+// the difference from each value to the next, all as varints. Differences
+// wrap on overflow, which accumulation on decode undoes.
+func writeDeltaInts(writer *Writer, v reflect.Value) error {
+	n := v.Len()
+	if err := writer.WriteCollectionHeader(n); err != nil {
+		return err
+	}
+
+	var prev int64
+	for i := range n {
+		cur := v.Index(i).Int()
+		writer.WriteVarint(cur - prev)
+		prev = cur
+	}
+	return nil
+}
+
+// readDeltaInts reads a slice written by writeDeltaInts.
+func readDeltaInts(reader *Reader, v reflect.Value) error {
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil {
+		return err
+	}
+	if isNull {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if err = reader.checkLength(length, v.Type().Elem()); err != nil {
+		return err
+	}
+	if err = reader.allocate(length, v.Type().Elem().Size()); err != nil {
+		return err
+	}
+	slice := reflect.MakeSlice(v.Type(), length, length)
+	var prev int64
+	for i := range length {
+		delta, err := reader.ReadVarint()
+		if err != nil {
+			return err
+		}
+		prev += delta
+		slice.Index(i).SetInt(prev)
+	}
+	v.Set(slice)
+	return nil
+}
@@ -0,0 +1,183 @@
+package memorypack
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"slices"
+)
+
+// Map layouts written after the header of maps with DenseIntMaps.
+const (
+	denseMapPairs byte = 0
+	denseMapBits  byte = 1
+)
+
+// isDenseMap reports whether a map of t may be written as a dense array with
+// opts.
+func isDenseMap(t reflect.Type, opts *Options) bool {
+	if !opts.DenseIntMaps {
+		return false
+	}
+	key := t.Key()
+	return (isSignedInt(key.Kind()) || isUnsignedInt(key.Kind())) && !isEnumType(key) && !isFormatterType(key)
+}
+
+// denseMapSpan returns n such that all keys of a non-nil integer map lie in
+// [0, n), and whether a bitmap of n bits is smaller than writing the keys.
+func denseMapSpan(v reflect.Value) (int, bool) {
+	var span uint64
+	iter := v.MapRange()
+	for iter.Next() {
+		key := iter.Key()
+		var k uint64
+		if isSignedInt(key.Kind()) {
+			if key.Int() < 0 {
+				return 0, false
+			}
+			k = uint64(key.Int())
+		} else {
+			k = key.Uint()
+		}
+		if k >= math.MaxInt32 {
+			return 0, false
+		}
+		span = max(span, k+1)
+	}
+	n := int(span)
+	return n, 4+(n+7)/8 < v.Len()*int(v.Type().Key().Size())
+}
+
+// writeDenseMap writes the layout byte of a non-nil map whose header has been
+// written, followed by the map as a span, a bitmap of the keys present and
+// their values in key order when that is smaller than key/value pairs. It
+// reports whether it wrote the entries.
+func writeDenseMap(writer *Writer, v reflect.Value) (bool, error) {
+	n, dense := denseMapSpan(v)
+	if !dense {
+		writer.WriteByte(denseMapPairs)
+		return false, nil
+	}
+
+	writer.WriteByte(denseMapBits)
+	writer.WriteInt32(int32(n))
+	size := (n + 7) / 8
+	writer.ensureCapacity(size)
+	bitmap := writer.buffer[writer.pos : writer.pos+size]
+	clear(bitmap)
+	iter := v.MapRange()
+	for iter.Next() {
+		i := denseMapIndex(iter.Key())
+		bitmap[i/8] |= 1 << (i % 8)
+	}
+	writer.pos += size
+
+	key := reflect.New(v.Type().Key()).Elem()
+	for i := range n {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		setDenseMapKey(key, i)
+		if err := writeValue(writer, v.MapIndex(key)); err != nil {
+			return true, withKey(err, key)
+		}
+	}
+	return true, nil
+}
+
+// readDenseMap reads the layout byte of a map with length entries and, if it
+// is dense, the entries, storing the map in v. It reports whether it read the
+// entries.
+func readDenseMap(reader *Reader, v reflect.Value, length int) (bool, error) {
+	bitmap, err := readDenseMapBitmap(reader, length)
+	if bitmap == nil || err != nil {
+		return false, err
+	}
+
+	mapType := v.Type()
+	mapValue := reflect.MakeMapWithSize(mapType, length)
+	for i := range len(bitmap) * 8 {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		key := reflect.New(mapType.Key()).Elem()
+		if isSignedInt(key.Kind()) {
+			if key.OverflowInt(int64(i)) {
+				return true, fmt.Errorf("map key %d overflows %s", i, key.Type())
+			}
+		} else if key.OverflowUint(uint64(i)) {
+			return true, fmt.Errorf("map key %d overflows %s", i, key.Type())
+		}
+		setDenseMapKey(key, i)
+		value := reflect.New(mapType.Elem()).Elem()
+		if err = readValue(reader, value); err != nil {
+			return true, err
+		}
+		mapValue.SetMapIndex(key, value)
+		reader.reportProgress()
+	}
+	v.Set(mapValue)
+	return true, nil
+}
+
+// readDenseMapBitmap reads the layout byte of a map with length entries and,
+// if it is dense, its span and key bitmap. The bitmap is nil for key/value
+// pairs.
+func readDenseMapBitmap(reader *Reader, length int) ([]byte, error) {
+	layout, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch layout {
+	case denseMapPairs:
+		return nil, nil
+	case denseMapBits:
+	default:
+		return nil, fmt.Errorf("%w: map layout %d", ErrInvalidHeader, layout)
+	}
+
+	n, err := reader.ReadInt32()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("%w: dense map span %d", ErrInvalidHeader, n)
+	}
+	size := (int(n) + 7) / 8
+	if !reader.ensure(size) {
+		return nil, fmt.Errorf("cannot read dense map bitmap of %d bytes: %w", size, ErrTruncated)
+	}
+	// Copy the bitmap, since reading the values may refill a streamed buffer.
+	bitmap := slices.Clone(reader.buffer[reader.pos : reader.pos+size])
+	reader.pos += size
+
+	count := 0
+	for _, b := range bitmap {
+		count += bits.OnesCount8(b)
+	}
+	if unused := int(n) % 8; unused != 0 && bitmap[size-1]>>unused != 0 {
+		return nil, fmt.Errorf("dense map bitmap marks keys beyond its span of %d", n)
+	}
+	if count != length {
+		return nil, fmt.Errorf("dense map bitmap marks %d keys, header declares %d", count, length)
+	}
+	return bitmap, nil
+}
+
+// denseMapIndex returns the bitmap index of a non-negative integer key.
+func denseMapIndex(key reflect.Value) int {
+	if isSignedInt(key.Kind()) {
+		return int(key.Int())
+	}
+	return int(key.Uint())
+}
+
+// setDenseMapKey sets an integer key to i.
+func setDenseMapKey(key reflect.Value, i int) {
+	if isSignedInt(key.Kind()) {
+		key.SetInt(int64(i))
+	} else {
+		key.SetUint(uint64(i))
+	}
+}
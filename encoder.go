@@ -0,0 +1,100 @@
+package memorypack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// frameHeaderSize is the size of the length prefix written before each message.
+const frameHeaderSize = 4
+
+// Encoder writes a sequence of values to an io.Writer.
+//
+// Each value is written as a frame: its encoded length as a little-endian
+// int32 followed by the encoded bytes. A Decoder reads the values back in order.
+type Encoder struct {
+	w      io.Writer
+	writer *Writer
+}
+
+// NewEncoder creates an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWithOptions(w, Options{})
+}
+
+// NewEncoderWithOptions creates an Encoder that writes to w using the given options.
+func NewEncoderWithOptions(w io.Writer, opts Options) *Encoder {
+	return &Encoder{w: w, writer: NewWriterWithOptions(128, opts)}
+}
+
+// Encode writes value to the stream as a single frame.
+func (e *Encoder) Encode(value any) error {
+	e.writer.Reset()
+	e.writer.WriteInt32(0) // patched below
+	if err := encode(e.writer, value); err != nil {
+		return err
+	}
+
+	frame := e.writer.GetBytes()
+	binary.LittleEndian.PutUint32(frame, uint32(len(frame)-frameHeaderSize))
+	n, err := e.w.Write(frame)
+	if err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	if n != len(frame) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// Decoder reads a sequence of values written by an Encoder.
+type Decoder struct {
+	src     io.Reader
+	options Options
+	buffer  []byte
+}
+
+// NewDecoder creates a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, Options{})
+}
+
+// NewDecoderWithOptions creates a Decoder that reads from r using the given options.
+//
+// The options must match the ones the stream was encoded with.
+func NewDecoderWithOptions(r io.Reader, opts Options) *Decoder {
+	return &Decoder{src: r, options: opts}
+}
+
+// Decode reads the next frame from the stream into value.
+//
+// value must be a pointer to a value. Decode returns io.EOF when the stream
+// ends cleanly between frames, and io.ErrUnexpectedEOF when it ends inside one.
+func (d *Decoder) Decode(value any) error {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(d.src, header[:]); err != nil {
+		return err
+	}
+	length := int32(binary.LittleEndian.Uint32(header[:]))
+	if length < 0 {
+		return fmt.Errorf("invalid frame length %d", length)
+	}
+
+	// Read the frame through a stream reader so that a corrupt length cannot
+	// force an allocation larger than the data that actually arrives.
+	reader := &Reader{buffer: d.buffer[:0], options: d.options, src: io.LimitReader(d.src, int64(length))}
+	if !reader.ensure(int(length)) {
+		return io.ErrUnexpectedEOF
+	}
+	reader.src = nil
+	d.buffer = reader.buffer
+
+	if err := decode(reader, value); err != nil {
+		return err
+	}
+	if reader.pos != int(length) {
+		return fmt.Errorf("frame has %d trailing bytes", int(length)-reader.pos)
+	}
+	return nil
+}
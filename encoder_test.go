@@ -0,0 +1,123 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestEncoder tests encoding a sequence of values and decoding them in order.
+func TestEncoder(t *testing.T) {
+	type Event struct {
+		Name string
+		Tags []string
+	}
+
+	values := []any{
+		int32(42),
+		"hello",
+		Event{Name: "start", Tags: []string{"a", "b"}},
+		[]int64{1, -2, 3},
+		map[string]float64{"pi": 3.14},
+		Event{Name: "stop"},
+	}
+
+	var buf bytes.Buffer
+	enc := memorypack.NewEncoder(&buf)
+	for _, value := range values {
+		if err := enc.Encode(value); err != nil {
+			t.Fatalf("Encode(%v) failed: %v", value, err)
+		}
+	}
+	stream := buf.Bytes()
+
+	t.Run("Sequence", func(t *testing.T) {
+		dec := memorypack.NewDecoder(bytes.NewReader(stream))
+		for _, want := range values {
+			got := reflect.New(reflect.TypeOf(want))
+			if err := dec.Decode(got.Interface()); err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), want) {
+				t.Errorf("Got %v, want %v", got.Elem().Interface(), want)
+			}
+		}
+
+		var extra int32
+		if err := dec.Decode(&extra); err != io.EOF {
+			t.Errorf("Expected io.EOF after the last frame, got %v", err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		dec := memorypack.NewDecoder(bytes.NewReader(stream[:len(stream)-2]))
+		var err error
+		for _, want := range values {
+			if err = dec.Decode(reflect.New(reflect.TypeOf(want)).Interface()); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+		}
+	})
+
+	t.Run("LoopUntilEOF", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := memorypack.NewEncoder(&buf)
+		for _, name := range []string{"a", "b", "c"} {
+			if err := enc.Encode(Event{Name: name}); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+		}
+
+		// A partial frame header after the last message is not a clean end.
+		partial := append(buf.Bytes(), 5, 0)
+		for _, tc := range []struct {
+			name string
+			data []byte
+			end  error
+		}{
+			{"Clean", buf.Bytes(), io.EOF},
+			{"PartialHeader", partial, io.ErrUnexpectedEOF},
+		} {
+			dec := memorypack.NewDecoder(bytes.NewReader(tc.data))
+			var names []string
+			var err error
+			for {
+				var event Event
+				if err = dec.Decode(&event); err != nil {
+					break
+				}
+				names = append(names, event.Name)
+			}
+			if err != tc.end {
+				t.Errorf("%s: expected %v at the end, got %v", tc.name, tc.end, err)
+			}
+			if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("%s: got %v, want %v", tc.name, names, want)
+			}
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		opts := memorypack.Options{CompactMapKeys: true}
+		original := map[int]string{-1: "a", 100: "b"}
+
+		var buf bytes.Buffer
+		if err := memorypack.NewEncoderWithOptions(&buf, opts).Encode(original); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		var result map[int]string
+		if err := memorypack.NewDecoderWithOptions(&buf, opts).Decode(&result); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if !reflect.DeepEqual(original, result) {
+			t.Errorf("Got %v, want %v", result, original)
+		}
+	})
+}
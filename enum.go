@@ -0,0 +1,117 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	enumTypes sync.Map // map[reflect.Type]*enumNames
+	hasEnums  atomic.Bool
+)
+
+// enumNames maps between the names and values of a registered enum type.
+type enumNames struct {
+	byName  map[string]int64
+	byValue map[int64]string
+}
+
+// RegisterEnum registers names for the values of an integer enum type.
+//
+// Values of t are then encoded as their names instead of their numbers, and
+// decoding a name missing from names fails. Serializing a value without a name
+// fails as well. Values that t cannot hold are rejected.
+func RegisterEnum(t reflect.Type, names map[string]int64) error {
+	if t == nil {
+		return fmt.Errorf("cannot register an enum for nil")
+	}
+	if !isSignedInt(t.Kind()) && !isUnsignedInt(t.Kind()) {
+		return fmt.Errorf("cannot register enum %s: enums must be integers", t)
+	}
+
+	enum := &enumNames{
+		byName:  make(map[string]int64, len(names)),
+		byValue: make(map[int64]string, len(names)),
+	}
+	zero := reflect.Zero(t)
+	for name, value := range names {
+		if isUnsignedInt(t.Kind()) && zero.OverflowUint(uint64(value)) || isSignedInt(t.Kind()) && zero.OverflowInt(value) {
+			return fmt.Errorf("enum %s value %d of %q overflows %s", t, value, name, t.Kind())
+		}
+		if other, found := enum.byValue[value]; found {
+			return fmt.Errorf("enum %s maps both %q and %q to %d", t, other, name, value)
+		}
+		enum.byName[name] = value
+		enum.byValue[value] = name
+	}
+	enumTypes.Store(t, enum)
+	hasEnums.Store(true)
+	return nil
+}
+
+// lookupEnum returns the names registered for t, if any.
+func lookupEnum(t reflect.Type) (*enumNames, bool) {
+	if !hasEnums.Load() {
+		return nil, false
+	}
+	enum, ok := enumTypes.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return enum.(*enumNames), true
+}
+
+// isEnumType reports whether t is registered with RegisterEnum.
+func isEnumType(t reflect.Type) bool {
+	_, ok := lookupEnum(t)
+	return ok
+}
+
+// writeEnum writes v as its name if its type is a registered enum.
+func writeEnum(writer *Writer, v reflect.Value) (bool, error) {
+	enum, ok := lookupEnum(v.Type())
+	if !ok {
+		return false, nil
+	}
+
+	value := enumValue(v)
+	name, found := enum.byValue[value]
+	if !found {
+		return true, fmt.Errorf("value %d of %s has no registered name", value, v.Type())
+	}
+	writer.WriteString(name)
+	return true, nil
+}
+
+// readEnum reads a name written by writeEnum into v.
+func readEnum(reader *Reader, v reflect.Value) (bool, error) {
+	enum, ok := lookupEnum(v.Type())
+	if !ok {
+		return false, nil
+	}
+
+	name, err := reader.ReadString()
+	if err != nil {
+		return true, err
+	}
+	value, found := enum.byName[name]
+	if !found {
+		return true, fmt.Errorf("unknown %s name %q", v.Type(), name)
+	}
+	if isUnsignedInt(v.Kind()) {
+		v.SetUint(uint64(value))
+	} else {
+		v.SetInt(value)
+	}
+	return true, nil
+}
+
+// enumValue returns the integer held by v as an int64.
+func enumValue(v reflect.Value) int64 {
+	if isUnsignedInt(v.Kind()) {
+		return int64(v.Uint())
+	}
+	return v.Int()
+}
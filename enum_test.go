@@ -0,0 +1,88 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type status int32
+
+const (
+	statusPending status = iota
+	statusActive
+	statusClosed
+)
+
+// TestRegisterEnum tests encoding registered enums as their names.
+func TestRegisterEnum(t *testing.T) {
+	names := map[string]int64{"Pending": 0, "Active": 1, "Closed": 2}
+	if err := memorypack.RegisterEnum(reflect.TypeFor[status](), names); err != nil {
+		t.Fatalf("RegisterEnum failed: %v", err)
+	}
+
+	type Order struct {
+		ID      int32
+		Status  status
+		History []status
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		testRoundTrip(t, Order{ID: 1, Status: statusActive, History: []status{statusPending, statusActive}})
+		testRoundTrip(t, map[status]string{statusClosed: "done"})
+	})
+
+	t.Run("WrittenAsName", func(t *testing.T) {
+		data, err := memorypack.Serialize(&Order{Status: statusClosed})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if !bytes.Contains(data, []byte("Closed")) {
+			t.Errorf("Expected the name Closed in % x", data)
+		}
+	})
+
+	t.Run("UnknownName", func(t *testing.T) {
+		type wireOrder struct {
+			ID      int32
+			Status  string
+			History []string
+		}
+		data, err := memorypack.Serialize(&wireOrder{Status: "Archived"})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result Order
+		if err = memorypack.Deserialize(data, &result); err == nil {
+			t.Error("Expected error for an unknown name, got nil")
+		}
+	})
+
+	t.Run("UnnamedValue", func(t *testing.T) {
+		if _, err := memorypack.Serialize(&Order{Status: 7}); err == nil {
+			t.Error("Expected error for a value without a name, got nil")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if err := memorypack.RegisterEnum(reflect.TypeFor[string](), names); err == nil {
+			t.Error("Expected error for a non-integer type")
+		}
+		duplicate := map[string]int64{"A": 1, "B": 1}
+		if err := memorypack.RegisterEnum(reflect.TypeFor[status](), duplicate); err == nil {
+			t.Error("Expected error for duplicate values")
+		}
+		type level uint8
+		if err := memorypack.RegisterEnum(reflect.TypeFor[level](), map[string]int64{"Max": 300}); err == nil {
+			t.Error("Expected error for a value overflowing uint8")
+		}
+		if err := memorypack.RegisterEnum(reflect.TypeFor[level](), map[string]int64{"Low": -1}); err == nil {
+			t.Error("Expected error for a negative unsigned value")
+		}
+		if err := memorypack.RegisterEnum(reflect.TypeFor[status](), map[string]int64{"Huge": 1 << 40}); err == nil {
+			t.Error("Expected error for a value overflowing int32")
+		}
+	})
+}
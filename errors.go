@@ -0,0 +1,192 @@
+package memorypack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Errors returned by Serialize and Deserialize wrap one of these, where one
+// applies, so that callers can test for them with errors.Is.
+var (
+	// ErrTruncated reports data that ends before the value it encodes.
+	ErrTruncated = errors.New("end of buffer")
+
+	// ErrDepthExceeded reports values nested more than MaxDepth levels deep.
+	ErrDepthExceeded = errors.New("depth exceeded")
+
+	// ErrFieldCountMismatch reports a struct whose object header declares a
+	// different number of fields than the target type.
+	ErrFieldCountMismatch = errors.New("field count mismatch")
+
+	// ErrUnsupportedType reports a Go type that has no MemoryPack encoding.
+	ErrUnsupportedType = errors.New("unsupported type")
+
+	// ErrInvalidHeader reports an object, collection, union or reference
+	// header holding a reserved or out of range value.
+	ErrInvalidHeader = errors.New("invalid header")
+)
+
+// Layer kinds in an encoded error chain.
+const (
+	errorLayerMessage    byte = 0
+	errorLayerRegistered byte = 1
+	errorLayerUnion      byte = 2
+)
+
+var (
+	errorByName sync.Map // map[string]error
+	nameByError sync.Map // map[error]string
+)
+
+// RegisterError registers a sentinel error under a name, so that an error
+// value wrapping it decodes to a chain ending in err itself, for which
+// errors.Is reports true. The name is written in place of the error.
+func RegisterError(name string, err error) error {
+	if err == nil || !reflect.TypeOf(err).Comparable() {
+		return fmt.Errorf("cannot register %T as %q: sentinel errors must be comparable and non-nil", err, name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if existing, found := errorByName.Load(name); found {
+		if existing == err {
+			return nil
+		}
+		return fmt.Errorf("error name %q is already registered for %v", name, existing)
+	}
+	if existing, found := nameByError.Load(err); found {
+		return fmt.Errorf("error %v is already registered as %q", err, existing)
+	}
+	errorByName.Store(name, err)
+	nameByError.Store(err, name)
+	return nil
+}
+
+// registeredErrorName returns the name err is registered under.
+func registeredErrorName(err error) (string, bool) {
+	if !reflect.TypeOf(err).Comparable() {
+		return "", false
+	}
+	name, found := nameByError.Load(err)
+	if !found {
+		return "", false
+	}
+	return name.(string), true
+}
+
+// wrappedError is a decoded layer of an error chain, keeping the message of
+// the original layer and the layer it wrapped.
+type wrappedError struct {
+	msg  string
+	next error
+}
+
+func (e *wrappedError) Error() string { return e.msg }
+func (e *wrappedError) Unwrap() error { return e.next }
+
+// writeError writes the chain of errors reached from err through
+// errors.Unwrap, outermost first, as a collection of layers. Errors
+// registered with RegisterError are written by name, and errors whose type is
+// registered with RegisterType are written as unions; either ends the chain.
+// Other layers keep only their message. Errors wrapping several others, such
+// as those from errors.Join, are written as a message.
+func writeError(writer *Writer, err error) error {
+	if err == nil {
+		writer.WriteNullCollectionHeader()
+		return nil
+	}
+
+	var layers []error
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		layers = append(layers, e)
+		if errorLayerKind(e) != errorLayerMessage {
+			break
+		}
+	}
+	if err := writer.WriteCollectionHeader(len(layers)); err != nil {
+		return err
+	}
+	for _, e := range layers {
+		kind := errorLayerKind(e)
+		writer.WriteByte(kind)
+		switch kind {
+		case errorLayerRegistered:
+			name, _ := registeredErrorName(e)
+			writer.WriteString(name)
+		case errorLayerUnion:
+			if err := writeInterface(writer, reflect.ValueOf(&e).Elem()); err != nil {
+				return err
+			}
+		default:
+			writer.WriteString(e.Error())
+		}
+	}
+	return nil
+}
+
+// errorLayerKind returns how writeError writes the layer e.
+func errorLayerKind(e error) byte {
+	if _, found := registeredErrorName(e); found {
+		return errorLayerRegistered
+	}
+	if _, found := tagByType.Load(reflect.TypeOf(e)); found {
+		return errorLayerUnion
+	}
+	return errorLayerMessage
+}
+
+// readError reads an error chain written by writeError.
+func readError(reader *Reader) (error, error) {
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil || isNull {
+		return nil, err
+	}
+	if err = reader.checkLength(length, errorType); err != nil {
+		return nil, err
+	}
+
+	layers := make([]*wrappedError, 0, min(length, 64))
+	var last error
+	for i := range length {
+		kind, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if kind != errorLayerMessage && i != length-1 {
+			return nil, fmt.Errorf("%w: error layer kind %d must end the chain", ErrInvalidHeader, kind)
+		}
+		switch kind {
+		case errorLayerMessage:
+			s, err := reader.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, &wrappedError{msg: s})
+		case errorLayerRegistered:
+			s, err := reader.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			registered, found := errorByName.Load(s)
+			if !found {
+				return nil, fmt.Errorf("error name %q is not registered", s)
+			}
+			last = registered.(error)
+		case errorLayerUnion:
+			if err = readInterface(reader, reflect.ValueOf(&last).Elem()); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: error layer kind %d", ErrInvalidHeader, kind)
+		}
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		layers[i].next = last
+		last = layers[i]
+	}
+	return last, nil
+}
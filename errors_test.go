@@ -0,0 +1,217 @@
+package memorypack_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestErrors tests that failures wrap the matching sentinel error.
+func TestErrors(t *testing.T) {
+	type Pair struct {
+		A, B int32
+	}
+	type Triple struct {
+		A, B, C int32
+	}
+	pair, err := memorypack.Serialize(&Pair{A: 1, B: 2})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	cycle := &refNode{Name: "loop"}
+	cycle.Next = cycle
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{
+			name: "Truncated",
+			run:  func() error { return memorypack.Deserialize(pair[:len(pair)-1], &Pair{}) },
+			want: memorypack.ErrTruncated,
+		},
+		{
+			name: "TruncatedString",
+			run: func() error {
+				var s string
+				return memorypack.Deserialize([]byte{0xFA, 0xFF, 0xFF, 0xFF, 5, 0, 0, 0, 'a'}, &s)
+			},
+			want: memorypack.ErrTruncated,
+		},
+		{
+			name: "EmptyHeader",
+			run: func() error {
+				_, _, err := memorypack.PeekMemberCount(nil)
+				return err
+			},
+			want: memorypack.ErrTruncated,
+		},
+		{
+			name: "DepthExceeded",
+			run: func() error {
+				_, err := memorypack.Serialize(cycle)
+				return err
+			},
+			want: memorypack.ErrDepthExceeded,
+		},
+		{
+			name: "FieldCountMismatch",
+			run:  func() error { return memorypack.Deserialize(pair, &Triple{}) },
+			want: memorypack.ErrFieldCountMismatch,
+		},
+		{
+			name: "UnsupportedType",
+			run: func() error {
+				_, err := memorypack.Serialize(&struct{ C chan int }{})
+				return err
+			},
+			want: memorypack.ErrUnsupportedType,
+		},
+		{
+			name: "ReservedObjectHeader",
+			run: func() error {
+				_, _, err := memorypack.PeekMemberCount([]byte{251})
+				return err
+			},
+			want: memorypack.ErrInvalidHeader,
+		},
+		{
+			name: "NegativeLength",
+			run: func() error {
+				var s []int32
+				return memorypack.Deserialize([]byte{0xFE, 0xFF, 0xFF, 0xFF}, &s)
+			},
+			want: memorypack.ErrInvalidHeader,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Errorf("Expected error wrapping %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+// codeError is an error type registered for use in interface values.
+type codeError struct {
+	Code int32
+	Msg  string
+}
+
+func (e *codeError) Error() string { return fmt.Sprintf("%d: %s", e.Code, e.Msg) }
+
+// TestErrorChains tests round-tripping wrapped errors.
+func TestErrorChains(t *testing.T) {
+	if err := memorypack.RegisterError("not-found", errNotFound); err != nil {
+		t.Fatalf("RegisterError failed: %v", err)
+	}
+	type Result struct {
+		Err  error
+		Code int32
+	}
+
+	t.Run("Wrapped", func(t *testing.T) {
+		original := Result{Err: fmt.Errorf("load user: %w", fmt.Errorf("query row 7: %w", errNotFound)), Code: 404}
+		data, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result Result
+		if err = memorypack.Deserialize(data, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+
+		if !errors.Is(result.Err, errNotFound) {
+			t.Errorf("Expected the chain to reach the sentinel: %v", result.Err)
+		}
+		var messages []string
+		for e := result.Err; e != nil; e = errors.Unwrap(e) {
+			messages = append(messages, e.Error())
+		}
+		want := []string{"load user: query row 7: not found", "query row 7: not found", "not found"}
+		if !slices.Equal(messages, want) {
+			t.Errorf("Got layers %q, want %q", messages, want)
+		}
+		if result.Code != 404 {
+			t.Errorf("Expected Code 404, got %d", result.Code)
+		}
+	})
+
+	t.Run("Unregistered", func(t *testing.T) {
+		cause := errors.New("disk full")
+		original := fmt.Errorf("save: %w", errors.Join(cause, errNotFound))
+		data, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result error
+		if err = memorypack.Deserialize(data, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result.Error() != original.Error() {
+			t.Errorf("Got %q, want %q", result, original)
+		}
+		// The joined errors are flattened to their message.
+		if errors.Is(result, errNotFound) || errors.Unwrap(errors.Unwrap(result)) != nil {
+			t.Errorf("Expected a two-layer chain of messages, got %#v", result)
+		}
+	})
+
+	t.Run("RegisteredType", func(t *testing.T) {
+		if err := memorypack.RegisterType(340, reflect.TypeFor[*codeError]()); err != nil {
+			t.Fatalf("RegisterType failed: %v", err)
+		}
+		original := Result{Err: fmt.Errorf("call: %w", &codeError{Code: 503, Msg: "unavailable"})}
+		data, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result Result
+		if err = memorypack.Deserialize(data, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+
+		var ce *codeError
+		if !errors.As(result.Err, &ce) || *ce != (codeError{Code: 503, Msg: "unavailable"}) {
+			t.Errorf("Expected the chain to hold the *codeError, got %#v", result.Err)
+		}
+		if result.Err.Error() != "call: 503: unavailable" {
+			t.Errorf("Got %q", result.Err)
+		}
+
+		// An error of a registered type at the top level keeps its type.
+		var top error = &codeError{Code: 1, Msg: "x"}
+		data, err = memorypack.Serialize(&top)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var decoded error
+		if err = memorypack.Deserialize(data, &decoded); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if got, ok := decoded.(*codeError); !ok || *got != (codeError{Code: 1, Msg: "x"}) {
+			t.Errorf("Got %#v", decoded)
+		}
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		testRoundTrip(t, Result{Code: 200})
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		if err := memorypack.RegisterError("not-found", errors.New("other")); err == nil {
+			t.Error("Expected error registering a name twice")
+		}
+		if err := memorypack.RegisterError("not-found", errNotFound); err != nil {
+			t.Errorf("Expected registering the same error again to succeed, got %v", err)
+		}
+	})
+}
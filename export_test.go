@@ -0,0 +1,26 @@
+package memorypack
+
+import "reflect"
+
+// SetPODFastPath enables or disables bulk copying of plain-old-data structs
+// and returns the previous setting.
+func SetPODFastPath(enabled bool) bool {
+	prev := podFastPath
+	podFastPath = enabled
+	return prev
+}
+
+// SetMaxCollectionLength sets the longest collection a header may describe and
+// returns the previous limit, so that overflow can be tested without
+// allocating billions of elements.
+func SetMaxCollectionLength(n int) int {
+	prev := maxCollectionLength
+	maxCollectionLength = n
+	return prev
+}
+
+// SkipValue skips over a value of type t, as done for fields that cannot be
+// set.
+func SkipValue(reader *Reader, t reflect.Type) error {
+	return skipValue(reader, t)
+}
@@ -0,0 +1,156 @@
+package memorypack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"reflect"
+	"sync/atomic"
+)
+
+// Field encoding flags written before fields that carry a transform tag option.
+const (
+	fieldEncodingRaw       byte = 0
+	fieldEncodingGzip      byte = 1 << 0
+	fieldEncodingEncrypted byte = 1 << 1
+)
+
+// Cipher encrypts the encoded bytes of struct fields tagged "encrypt".
+// Decoding such a field fails unless its data was encrypted.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// registeredCipher wraps the Cipher set by RegisterCipher for atomic storage.
+type registeredCipher struct{ Cipher }
+
+var fieldCipher atomic.Pointer[registeredCipher]
+
+// RegisterCipher sets the Cipher used for fields tagged "encrypt", such as
+// `memorypack:"3,encrypt"`, replacing any previous one. A nil cipher
+// unregisters it, after which encrypted fields can be neither written nor read.
+func RegisterCipher(c Cipher) {
+	if c == nil {
+		fieldCipher.Store(nil)
+		return
+	}
+	fieldCipher.Store(&registeredCipher{c})
+}
+
+// loadCipher returns the registered Cipher for use on the named field.
+func loadCipher(name string) (Cipher, error) {
+	c := fieldCipher.Load()
+	if c == nil {
+		return nil, fmt.Errorf("field %s is encrypted but no cipher is registered", name)
+	}
+	return c.Cipher, nil
+}
+
+// writeField writes a struct field, applying any transforms from its tag.
+func writeField(writer *Writer, field fieldInfo, v reflect.Value) error {
+	if !field.transformed() {
+		return writeValue(writer, v)
+	}
+
+	sub := writer.fork()
+	if err := writeValue(sub, v); err != nil {
+		return err
+	}
+	if err := sub.Err(); err != nil {
+		return err
+	}
+	payload := sub.GetBytes()
+	flags := fieldEncodingRaw
+
+	if field.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return fmt.Errorf("compress field %s: %w", field.name, err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compress field %s: %w", field.name, err)
+		}
+		// Keep the raw encoding when compression does not pay off.
+		if buf.Len() < len(payload) {
+			payload, flags = buf.Bytes(), flags|fieldEncodingGzip
+		}
+	}
+	if field.encrypt {
+		c, err := loadCipher(field.name)
+		if err != nil {
+			return err
+		}
+		if payload, err = c.Encrypt(payload); err != nil {
+			return fmt.Errorf("encrypt field %s: %w", field.name, err)
+		}
+		flags |= fieldEncodingEncrypted
+	}
+
+	writer.WriteByte(flags)
+	writer.WriteBytes(payload)
+	return nil
+}
+
+// readField reads a struct field written by writeField.
+func readField(reader *Reader, field fieldInfo, v reflect.Value) error {
+	if !field.transformed() {
+		return readValue(reader, v)
+	}
+
+	flags, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	payload, err := reader.ReadBytes()
+	if err != nil {
+		return err
+	}
+	if flags&^(fieldEncodingGzip|fieldEncodingEncrypted) != 0 {
+		return fmt.Errorf("invalid encoding flags %d for field %s", flags, field.name)
+	}
+	// The flag must match the tag, or a tampered stream could downgrade an
+	// encrypted field to plaintext.
+	if encrypted := flags&fieldEncodingEncrypted != 0; encrypted != field.encrypt {
+		return fmt.Errorf("field %s is tagged encrypt=%t but its data has encrypted=%t", field.name, field.encrypt, encrypted)
+	}
+
+	if flags&fieldEncodingEncrypted != 0 {
+		c, err := loadCipher(field.name)
+		if err != nil {
+			return err
+		}
+		if payload, err = c.Decrypt(payload); err != nil {
+			return fmt.Errorf("decrypt field %s: %w", field.name, err)
+		}
+	}
+	if flags&fieldEncodingGzip != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("decompress field %s: %w", field.name, err)
+		}
+		var src io.Reader = zr
+		left, limited := reader.budgetLeft()
+		if limited {
+			// Stop one byte past the budget rather than inflating all of it.
+			src = io.LimitReader(zr, max(left, 0)+1)
+		}
+		if payload, err = io.ReadAll(src); err != nil {
+			return fmt.Errorf("decompress field %s: %w", field.name, err)
+		}
+		if err = reader.allocate(len(payload), 1); err != nil {
+			return fmt.Errorf("decompress field %s: %w", field.name, err)
+		}
+	}
+
+	sub := reader.fork(payload)
+	if err = readValue(sub, v); err != nil {
+		return err
+	}
+	if sub.pos != len(payload) {
+		return fmt.Errorf("field %s has %d trailing bytes", field.name, len(payload)-sub.pos)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	flagMasks    sync.Map // map[reflect.Type]uint64
+	hasFlagMasks atomic.Bool
+)
+
+// RegisterFlagMask registers the set of valid bits for an unsigned integer type
+// used as bit flags.
+//
+// Decoding a value of t with bits outside mask fails instead of silently
+// accepting flags this version does not know about.
+func RegisterFlagMask(t reflect.Type, mask uint64) error {
+	if t == nil {
+		return fmt.Errorf("cannot register a flag mask for nil")
+	}
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return fmt.Errorf("cannot register a flag mask for %s: flags must be unsigned integers", t)
+	}
+	flagMasks.Store(t, mask)
+	hasFlagMasks.Store(true)
+	return nil
+}
+
+// hasFlagMask reports whether a flag mask is registered for t.
+func hasFlagMask(t reflect.Type) bool {
+	_, ok := flagMasks.Load(t)
+	return ok
+}
+
+// checkFlagMask fails if bits has bits set outside the mask registered for t.
+func checkFlagMask(t reflect.Type, bits uint64) error {
+	if !hasFlagMasks.Load() {
+		return nil
+	}
+	mask, ok := flagMasks.Load(t)
+	if !ok {
+		return nil
+	}
+	if unknown := bits &^ mask.(uint64); unknown != 0 {
+		return fmt.Errorf("invalid %s value 0x%x: unknown flag bits 0x%x", t, bits, unknown)
+	}
+	return nil
+}
+
+// setUint stores an unsigned integer after checking it against any flag mask
+// registered for the type of v.
+func setUint(v reflect.Value, bits uint64) error {
+	if err := checkFlagMask(v.Type(), bits); err != nil {
+		return err
+	}
+	v.SetUint(bits)
+	return nil
+}
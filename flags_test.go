@@ -0,0 +1,62 @@
+package memorypack_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type perm uint8
+
+const (
+	permRead perm = 1 << iota
+	permWrite
+	permExec
+)
+
+// TestRegisterFlagMask tests rejecting unknown bits in flag types.
+func TestRegisterFlagMask(t *testing.T) {
+	if err := memorypack.RegisterFlagMask(reflect.TypeFor[perm](), uint64(permRead|permWrite|permExec)); err != nil {
+		t.Fatalf("RegisterFlagMask failed: %v", err)
+	}
+
+	type File struct {
+		Name  string
+		Perms perm
+	}
+
+	t.Run("KnownBits", func(t *testing.T) {
+		testRoundTrip(t, File{Name: "a", Perms: permRead | permExec})
+		testRoundTrip(t, []perm{0, permWrite, permRead | permWrite | permExec})
+	})
+
+	t.Run("UnknownBits", func(t *testing.T) {
+		data, err := memorypack.Serialize(&File{Name: "a", Perms: permRead | 1<<3})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result File
+		err = memorypack.Deserialize(data, &result)
+		if err == nil || !strings.Contains(err.Error(), "unknown flag bits 0x8") {
+			t.Errorf("Expected unknown flag bits error, got %v", err)
+		}
+
+		data, err = memorypack.Serialize([]perm{permRead, 1 << 4})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var slice []perm
+		if err = memorypack.Deserialize(data, &slice); err == nil {
+			t.Error("Expected unknown flag bits error in slice, got nil")
+		}
+	})
+
+	t.Run("NotUnsigned", func(t *testing.T) {
+		if err := memorypack.RegisterFlagMask(reflect.TypeFor[int](), 1); err == nil {
+			t.Error("Expected error registering a signed type, got nil")
+		}
+	})
+}
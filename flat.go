@@ -0,0 +1,196 @@
+package memorypack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// flatHeaderSize is the length of the row count and row size that start a
+// flat layout.
+const flatHeaderSize = 8
+
+// flatField is the position of one struct field within a flat row.
+type flatField struct {
+	info   fieldInfo
+	typ    reflect.Type
+	offset int
+	size   int
+}
+
+// flatLayout returns the fields of struct type t and the size of a row.
+// Strings take eight bytes: an int32 offset into the blob and an int32 length.
+func flatLayout(t reflect.Type) ([]flatField, int, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, 0, fmt.Errorf("flat layout requires a struct type, got %s", t)
+	}
+	fields := getFormatterData(t).fields
+	layout := make([]flatField, 0, len(fields))
+	stride := 0
+	for _, field := range fields {
+		ft := t.Field(field.index).Type
+		var size int
+		switch ft.Kind() {
+		case reflect.Bool, reflect.Int8, reflect.Uint8:
+			size = 1
+		case reflect.Int16, reflect.Uint16:
+			size = 2
+		case reflect.Int32, reflect.Uint32, reflect.Float32:
+			size = 4
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64, reflect.String:
+			size = 8
+		default:
+			return nil, 0, fmt.Errorf("field %s of %s has type %s, which the flat layout does not support", field.name, t, ft)
+		}
+		layout = append(layout, flatField{info: field, typ: ft, offset: stride, size: size})
+		stride += size
+	}
+	return layout, stride, nil
+}
+
+// SerializeFlat writes rows in an experimental columnar-friendly layout: an
+// int32 row count and row size, then one fixed-size record per row, then a
+// blob holding the bytes of every string. Any field of any row can be read
+// with DeserializeFlatField without decoding the rest.
+//
+// Only structs whose fields are booleans, numbers and strings are supported.
+// The layout is specific to this package and may change.
+func SerializeFlat[T any](rows []T) ([]byte, error) {
+	layout, stride, err := flatLayout(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCollectionLength(len(rows)); err != nil {
+		return nil, err
+	}
+
+	writer := NewWriter(flatHeaderSize + len(rows)*stride)
+	writer.WriteInt32(int32(len(rows)))
+	writer.WriteInt32(int32(stride))
+	var blob []byte
+	for i := range rows {
+		row := reflect.ValueOf(&rows[i]).Elem()
+		for _, field := range layout {
+			fv := row.Field(field.info.index)
+			switch field.size {
+			case 1:
+				if fv.Kind() == reflect.Bool {
+					writer.WriteBool(fv.Bool())
+				} else {
+					writer.WriteByte(byte(flatBits(fv)))
+				}
+			case 2:
+				writer.WriteInt16(int16(flatBits(fv)))
+			case 4:
+				writer.WriteInt32(int32(flatBits(fv)))
+			default:
+				if fv.Kind() != reflect.String {
+					writer.WriteInt64(int64(flatBits(fv)))
+					continue
+				}
+				if len(blob)+fv.Len() > math.MaxInt32 {
+					return nil, fmt.Errorf("flat string blob exceeds %d bytes", math.MaxInt32)
+				}
+				writer.WriteInt32(int32(len(blob)))
+				writer.WriteInt32(int32(fv.Len()))
+				blob = append(blob, fv.String()...)
+			}
+		}
+	}
+	return append(writer.GetBytes(), blob...), nil
+}
+
+// flatBits returns the bits of a numeric value as written in a flat row.
+func flatBits(v reflect.Value) uint64 {
+	switch {
+	case isSignedInt(v.Kind()):
+		return uint64(v.Int())
+	case v.Kind() == reflect.Float32:
+		return uint64(math.Float32bits(float32(v.Float())))
+	case v.Kind() == reflect.Float64:
+		return math.Float64bits(v.Float())
+	default:
+		return v.Uint()
+	}
+}
+
+// DeserializeFlatField reads the named field of one row of data written by
+// SerializeFlat for []T. F must be the type of the field.
+func DeserializeFlatField[T, F any](data []byte, row int, name string) (F, error) {
+	var result F
+	t := reflect.TypeFor[T]()
+	layout, stride, err := flatLayout(t)
+	if err != nil {
+		return result, err
+	}
+	if len(data) < flatHeaderSize {
+		return result, fmt.Errorf("cannot read flat header: %w", ErrTruncated)
+	}
+	count := int(int32(binary.LittleEndian.Uint32(data)))
+	if got := int(int32(binary.LittleEndian.Uint32(data[4:]))); got != stride {
+		return result, fmt.Errorf("flat rows are %d bytes, but rows of %s are %d", got, t, stride)
+	}
+	if count < 0 || (len(data)-flatHeaderSize)/max(stride, 1) < count {
+		return result, fmt.Errorf("flat layout of %d rows: %w", count, ErrTruncated)
+	}
+	if row < 0 || row >= count {
+		return result, fmt.Errorf("row %d out of range for %d rows", row, count)
+	}
+
+	var field *flatField
+	for i := range layout {
+		if layout[i].info.name == name {
+			field = &layout[i]
+			break
+		}
+	}
+	if field == nil {
+		return result, fmt.Errorf("%s has no field %s", t, name)
+	}
+	v := reflect.ValueOf(&result).Elem()
+	if v.Type() != field.typ {
+		return result, fmt.Errorf("field %s of %s has type %s, not %s", name, t, field.typ, v.Type())
+	}
+
+	b := data[flatHeaderSize+row*stride+field.offset:]
+	switch field.size {
+	case 1:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(b[0] != 0)
+		} else {
+			setFlatBits(v, uint64(b[0]), 8)
+		}
+	case 2:
+		setFlatBits(v, uint64(binary.LittleEndian.Uint16(b)), 16)
+	case 4:
+		setFlatBits(v, uint64(binary.LittleEndian.Uint32(b)), 32)
+	default:
+		if v.Kind() != reflect.String {
+			setFlatBits(v, binary.LittleEndian.Uint64(b), 64)
+			break
+		}
+		blob := data[flatHeaderSize+count*stride:]
+		offset, length := int(binary.LittleEndian.Uint32(b)), int(binary.LittleEndian.Uint32(b[4:]))
+		if offset > len(blob) || length > len(blob)-offset {
+			return result, fmt.Errorf("string of row %d exceeds the flat blob: %w", row, ErrTruncated)
+		}
+		v.SetString(string(blob[offset : offset+length]))
+	}
+	return result, nil
+}
+
+// setFlatBits sets the numeric value v from bits of the given width.
+func setFlatBits(v reflect.Value, bits uint64, width int) {
+	switch {
+	case isSignedInt(v.Kind()):
+		shift := 64 - width
+		v.SetInt(int64(bits<<shift) >> shift)
+	case v.Kind() == reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(uint32(bits))))
+	case v.Kind() == reflect.Float64:
+		v.SetFloat(math.Float64frombits(bits))
+	default:
+		v.SetUint(bits)
+	}
+}
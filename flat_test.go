@@ -0,0 +1,70 @@
+package memorypack_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestFlat tests reading single fields of a flat layout.
+func TestFlat(t *testing.T) {
+	type Row struct {
+		ID     int64
+		Name   string
+		Score  float32
+		Level  int8
+		Active bool
+		Code   uint16
+	}
+
+	rows := make([]Row, 1000)
+	for i := range rows {
+		rows[i] = Row{
+			ID: int64(i) - 10, Name: "row-" + strconv.Itoa(i), Score: float32(i) / 2,
+			Level: int8(i % 256), Active: i%2 == 0, Code: uint16(i * 7),
+		}
+	}
+	data, err := memorypack.SerializeFlat(rows)
+	if err != nil {
+		t.Fatalf("SerializeFlat failed: %v", err)
+	}
+
+	name, err := memorypack.DeserializeFlatField[Row, string](data, 500, "Name")
+	if err != nil || name != "row-500" {
+		t.Errorf("Got Name %q, err: %v", name, err)
+	}
+	if id, err := memorypack.DeserializeFlatField[Row, int64](data, 5, "ID"); err != nil || id != -5 {
+		t.Errorf("Got ID %d, err: %v", id, err)
+	}
+	if score, err := memorypack.DeserializeFlatField[Row, float32](data, 999, "Score"); err != nil || score != 499.5 {
+		t.Errorf("Got Score %v, err: %v", score, err)
+	}
+	if level, err := memorypack.DeserializeFlatField[Row, int8](data, 255, "Level"); err != nil || level != -1 {
+		t.Errorf("Got Level %d, err: %v", level, err)
+	}
+	if active, err := memorypack.DeserializeFlatField[Row, bool](data, 3, "Active"); err != nil || active {
+		t.Errorf("Got Active %v, err: %v", active, err)
+	}
+	if code, err := memorypack.DeserializeFlatField[Row, uint16](data, 999, "Code"); err != nil || code != 6993 {
+		t.Errorf("Got Code %d, err: %v", code, err)
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := memorypack.DeserializeFlatField[Row, string](data, 1000, "Name"); err == nil {
+			t.Error("Expected error for a row out of range")
+		}
+		if _, err := memorypack.DeserializeFlatField[Row, int64](data, 0, "Name"); err == nil {
+			t.Error("Expected error for the wrong field type")
+		}
+		if _, err := memorypack.DeserializeFlatField[Row, string](data, 0, "Missing"); err == nil {
+			t.Error("Expected error for an unknown field")
+		}
+		if _, err := memorypack.DeserializeFlatField[Row, string](data[:len(data)-1], 999, "Name"); err == nil {
+			t.Error("Expected error for a truncated blob")
+		}
+		if _, err := memorypack.SerializeFlat([]struct{ Tags []string }{{}}); err == nil {
+			t.Error("Expected error for an unsupported field type")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// isValueFunc reports whether t is a func taking no arguments and returning
+// one value, the only kind of func Options.InvokeFuncs can encode.
+func isValueFunc(t reflect.Type) bool {
+	return t.NumIn() == 0 && t.NumOut() == 1 && !t.IsVariadic()
+}
+
+// writeFunc writes the result of calling a func value, preceded by a
+// presence byte since the result itself may start with the null marker. A nil
+// func is written as null.
+func writeFunc(writer *Writer, v reflect.Value) error {
+	if !writer.options.InvokeFuncs || !isValueFunc(v.Type()) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	if v.IsNil() {
+		writer.WriteByte(NullObject)
+		return nil
+	}
+	writer.WriteByte(pointerPresent)
+	return writeValue(writer, v.Call(nil)[0])
+}
+
+// readFunc reads a value written by writeFunc and sets v to a func returning it.
+func readFunc(reader *Reader, v reflect.Value) error {
+	if !reader.options.InvokeFuncs || !isValueFunc(v.Type()) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	marker, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch marker {
+	case NullObject:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case pointerPresent:
+	default:
+		return fmt.Errorf("%w: func marker %d for %s", ErrInvalidHeader, marker, v.Type())
+	}
+
+	result := reflect.New(v.Type().Out(0)).Elem()
+	if err = readValue(reader, result); err != nil {
+		return err
+	}
+	v.Set(reflect.MakeFunc(v.Type(), func([]reflect.Value) []reflect.Value {
+		return []reflect.Value{result}
+	}))
+	return nil
+}
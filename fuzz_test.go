@@ -0,0 +1,196 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"container/ring"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type fuzzNode struct {
+	Name     string
+	Children []*fuzzNode
+	Next     *fuzzNode
+}
+
+type fuzzRecord struct {
+	ID      int64
+	Flag    bool
+	Small   int8
+	Ratio   float32
+	Name    string
+	Data    []byte
+	Tags    []string
+	Bools   []bool
+	Fixed   [4]int16
+	Nested  [][2]uint32
+	ByName  map[string]int32
+	ByAny   map[any]any
+	Values  []any
+	At      time.Time
+	Node    *fuzzNode
+	Custom  CustomFormat
+	Pointer *int32
+}
+
+// fuzzOptions are the option sets each input is decoded with.
+var fuzzOptions = []memorypack.Options{
+	{},
+	{TrackReferences: true},
+	{TaggedPrimitives: true, LenientNumeric: true},
+	{KeyedFields: true},
+	{CompactMapKeys: true, PackBools: true, DeltaInts: true, CompactStrings: true},
+}
+
+// fuzzDecode decodes data into each fuzz target type with every option set.
+func fuzzDecode(data []byte) {
+	for _, opts := range fuzzOptions {
+		var record fuzzRecord
+		_ = memorypack.DeserializeWithOptions(data, &record, opts)
+		var node fuzzNode
+		_ = memorypack.DeserializeWithOptions(data, &node, opts)
+		var value any
+		_ = memorypack.DeserializeWithOptions(data, &value, opts)
+		var array [3]string
+		_ = memorypack.DeserializeWithOptions(data, &array, opts)
+		var ints []int64
+		_ = memorypack.DeserializeWithOptions(data, &ints, opts)
+	}
+}
+
+// FuzzDeserialize checks that decoding arbitrary input returns an error
+// rather than panicking.
+func FuzzDeserialize(f *testing.F) {
+	n := int32(7)
+	seeds := []any{
+		&fuzzRecord{
+			ID: 1, Flag: true, Name: "seed", Data: []byte{1, 2}, Tags: []string{"a"},
+			Bools: []bool{true, false}, Fixed: [4]int16{1, 2, 3, 4}, Nested: [][2]uint32{{5, 6}},
+			ByName: map[string]int32{"k": 1}, ByAny: map[any]any{"k": int64(1)},
+			Values: []any{int32(1), "two", nil}, At: time.Unix(1, 2),
+			Node:   &fuzzNode{Name: "n", Children: []*fuzzNode{{Name: "c"}}},
+			Custom: CustomFormat{IntValue: 3, StrValue: "c"}, Pointer: &n,
+		},
+		&fuzzNode{Name: "root", Next: &fuzzNode{Name: "next"}},
+		&[3]string{"x", "y", "z"},
+		&[]int64{1, 2, 3},
+	}
+	for _, seed := range seeds {
+		for _, opts := range fuzzOptions {
+			if data, err := memorypack.SerializeWithOptions(seed, opts); err == nil {
+				f.Add(data)
+			}
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDecode(data)
+	})
+}
+
+// TestFuzzRegressions holds inputs found by FuzzDeserialize that used to
+// panic.
+func TestFuzzRegressions(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		target any
+		want   string
+	}{
+		{
+			name:   "NilPointer",
+			data:   []byte{1, 0, 0, 0},
+			target: (*int32)(nil),
+			want:   "non-nil pointer",
+		},
+		{
+			name:   "NilFormatter",
+			data:   []byte{2, 1, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF},
+			target: (*CustomFormat)(nil),
+			want:   "non-nil pointer",
+		},
+		{
+			// Four elements into a [3]string indexed past the end of the
+			// array. Extra elements are now skipped, reaching the data's end.
+			name:   "ArrayOverflow",
+			data:   []byte{4, 0, 0, 0},
+			target: new([3]string),
+			want:   "end of buffer",
+		},
+		{
+			name:   "NegativeLength",
+			data:   []byte{0xFB, 0xFF, 0xFF, 0xFF},
+			target: new([]int64),
+			want:   "collection length",
+		},
+		{
+			// A 2^31-1 element header was allocated before reading any element.
+			name:   "HugeLength",
+			data:   []byte{0xFF, 0xFF, 0xFF, 0x7F},
+			target: new([]string),
+			want:   "exceeds",
+		},
+		{
+			name:   "HugeMapLength",
+			data:   []byte{0xFF, 0xFF, 0xFF, 0x7F},
+			target: new(map[string]int32),
+			want:   "exceeds",
+		},
+		{
+			// An interface key holding a []any cannot be stored in a map.
+			name: "UnhashableKey",
+			data: []byte{
+				1, 0, 0, 0,
+				0xFA, 0x0A, 0xFF, 0, 0, 0, 0,
+				1, 0, 0, 0,
+			},
+			target: new(map[any]int32),
+			want:   "not hashable",
+		},
+		{
+			// An array key holding a []any is unhashable too, and reporting
+			// its type called Elem on the array.
+			name: "UnhashableArrayKey",
+			data: []byte{
+				1, 0, 0, 0,
+				1, 0, 0, 0, 0xFA, 0x0A, 0xFF, 0, 0, 0, 0,
+				1, 0, 0, 0,
+			},
+			target: new(map[[1]any]int32),
+			want:   "not hashable",
+		},
+		{
+			// A ring node was allocated for each of 2^26 elements.
+			name:   "HugeRingLength",
+			data:   []byte{1, 0, 0, 0, 4},
+			target: new(struct{ R *ring.Ring }),
+			want:   "exceeds",
+		},
+		{
+			// Formatter elements were not checked against the data left.
+			name:   "HugeFormatterLength",
+			data:   []byte{0xFF, 0xFF, 0xFF, 0x7F},
+			target: new([]CustomFormat),
+			want:   "exceeds",
+		},
+		{
+			// Interfaces holding []any nested until the stack overflowed.
+			name:   "DeepNesting",
+			data:   bytes.Repeat([]byte{0xFA, 0x0A, 0xFF, 1, 0, 0, 0}, 1_000_000),
+			target: new(any),
+			want:   "depth exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := memorypack.Deserialize(tt.data, tt.target)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Deserialize() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
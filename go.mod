@@ -1,3 +1,3 @@
 module github.com/arisu-archive/memorypack-go
 
-go 1.22.5
+go 1.23
@@ -0,0 +1,56 @@
+package memorypack
+
+import "reflect"
+
+// Indexed is a collection whose elements can be read by position, such as a
+// custom container wrapping a slice, tree or ring.
+type Indexed[T any] interface {
+	Len() int
+	At(i int) T
+}
+
+// SerializeIndexed serializes the elements of c in order, in the same format
+// as a []T, so that the data can be decoded into a slice or with
+// DeserializeIndexed.
+func SerializeIndexed[T any](c Indexed[T]) ([]byte, error) {
+	n := c.Len()
+	writer := NewWriter(0)
+	if err := writer.WriteCollectionHeader(n); err != nil {
+		return nil, err
+	}
+	for i := range n {
+		elem := c.At(i)
+		if err := writeValue(writer, reflect.ValueOf(&elem).Elem()); err != nil {
+			return nil, withIndex(err, i)
+		}
+	}
+	if err := writer.Err(); err != nil {
+		return nil, err
+	}
+	return writer.GetBytes(), nil
+}
+
+// DeserializeIndexed decodes the elements of a serialized []T in order and
+// passes each to add, so that a custom collection can be rebuilt without an
+// intermediate slice. A null collection adds nothing.
+func DeserializeIndexed[T any](data []byte, add func(T) error) error {
+	reader := NewReader(data)
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil || isNull {
+		return err
+	}
+	if err = reader.checkLength(length, reflect.TypeFor[T]()); err != nil {
+		return err
+	}
+
+	for i := range length {
+		var elem T
+		if err = readValue(reader, reflect.ValueOf(&elem).Elem()); err != nil {
+			return withIndex(err, i)
+		}
+		if err = add(elem); err != nil {
+			return withIndex(err, i)
+		}
+	}
+	return nil
+}
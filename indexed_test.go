@@ -0,0 +1,63 @@
+package memorypack_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// sortedSet is a set of strings kept in order, readable by position.
+type sortedSet struct {
+	items []string
+}
+
+func (s *sortedSet) Add(v string) {
+	i, found := slices.BinarySearch(s.items, v)
+	if !found {
+		s.items = slices.Insert(s.items, i, v)
+	}
+}
+
+func (s *sortedSet) Len() int        { return len(s.items) }
+func (s *sortedSet) At(i int) string { return s.items[i] }
+
+// TestIndexed tests serializing a custom collection through Len and At.
+func TestIndexed(t *testing.T) {
+	var original sortedSet
+	for _, v := range []string{"pear", "apple", "fig", "apple"} {
+		original.Add(v)
+	}
+
+	data, err := memorypack.SerializeIndexed[string](&original)
+	if err != nil {
+		t.Fatalf("SerializeIndexed failed: %v", err)
+	}
+
+	var result sortedSet
+	err = memorypack.DeserializeIndexed(data, func(v string) error {
+		result.Add(v)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DeserializeIndexed failed: %v", err)
+	}
+	if !slices.Equal(result.items, original.items) {
+		t.Errorf("Got %q, want %q", result.items, original.items)
+	}
+
+	// The data has the layout of a []string.
+	var asSlice []string
+	if err = memorypack.Deserialize(data, &asSlice); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !slices.Equal(asSlice, original.items) {
+		t.Errorf("Got %q, want %q", asSlice, original.items)
+	}
+
+	stop := errors.New("stop")
+	if err = memorypack.DeserializeIndexed(data, func(string) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package memorypack
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// serializeKeyedStruct writes a struct in keyed mode: the object header is
+// followed, for each field, by the field name and the length-prefixed field value.
+func serializeKeyedStruct(writer *Writer, v reflect.Value, fd formatterData) error {
+	var unknown map[string]RawMessage
+	if fd.unknown != nil {
+		unknown = v.FieldByIndex(fd.unknown).Interface().(map[string]RawMessage)
+	}
+	if err := writer.WriteObjectHeader(len(fd.fields) + len(unknown)); err != nil {
+		return err
+	}
+
+	for _, field := range fd.fields {
+		sub := writer.fork()
+		if err := writeField(sub, field, v.Field(field.index)); err != nil {
+			return withField(err, field.name)
+		}
+		if err := sub.Err(); err != nil {
+			return withField(err, field.name)
+		}
+		writer.WriteString(field.name)
+		writer.WriteBytes(sub.GetBytes())
+	}
+
+	names := make([]string, 0, len(unknown))
+	for name := range unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if i, _ := lookupField(fd, name, false); i >= 0 {
+			return fmt.Errorf("unknown field %q duplicates a declared field", name)
+		}
+		writer.WriteString(name)
+		writer.WriteBytes(unknown[name])
+	}
+	return nil
+}
+
+// deserializeKeyedStruct reads a struct written by serializeKeyedStruct,
+// matching fields by name so that they may appear in any order. Fields the
+// struct does not declare are skipped, or collected into its unknown field.
+func deserializeKeyedStruct(reader *Reader, v reflect.Value, fd formatterData) error {
+	fieldCount, isNull, err := reader.ReadObjectHeader()
+	if err != nil || isNull {
+		return err
+	}
+
+	var unknown map[string]RawMessage
+	if fd.unknown != nil {
+		defer func() { v.FieldByIndex(fd.unknown).Set(reflect.ValueOf(unknown)) }()
+	}
+
+	seen := make([]bool, len(fd.fields))
+	for range fieldCount {
+		name, err := reader.ReadString()
+		if err != nil {
+			return err
+		}
+		payload, err := reader.ReadBytes()
+		if err != nil {
+			return err
+		}
+
+		i, err := lookupField(fd, name, reader.options.CaseInsensitiveFields)
+		if err != nil {
+			return err
+		}
+		if i < 0 {
+			// Fields added by a newer version of the type are skipped.
+			switch {
+			case fd.unknown != nil:
+				if unknown == nil {
+					unknown = make(map[string]RawMessage)
+				}
+				unknown[name] = bytes.Clone(payload)
+			case reader.options.StrictUnknownFields:
+				return fmt.Errorf("unknown field: %s", name)
+			}
+			continue
+		}
+		if seen[i] {
+			return fmt.Errorf("duplicate field %q", name)
+		}
+		seen[i] = true
+
+		field := fd.fields[i]
+		fieldValue := v.Field(field.index)
+		sub := reader.fork(payload)
+		if err = readField(sub, field, fieldValue); err != nil {
+			return err
+		}
+		if sub.pos != len(payload) {
+			return fmt.Errorf("field %s has %d trailing bytes", field.name, len(payload)-sub.pos)
+		}
+	}
+
+	var missing []fieldInfo
+	for i, field := range fd.fields {
+		if seen[i] {
+			continue
+		}
+		if !reader.options.AllowMissingFields {
+			return fmt.Errorf("missing field %s", field.name)
+		}
+		missing = append(missing, field)
+	}
+	return fillMissingFields(reader, v, missing)
+}
+
+// lookupField returns the index in fd.fields of the field with the given name,
+// or -1 if there is no such field.
+func lookupField(fd formatterData, name string, caseInsensitive bool) (int, error) {
+	match := -1
+	for i, field := range fd.fields {
+		switch {
+		case field.name == name:
+			return i, nil
+		case caseInsensitive && strings.EqualFold(field.name, name):
+			if match >= 0 {
+				return 0, fmt.Errorf("field %q matches both %s and %s", name, fd.fields[match].name, field.name)
+			}
+			match = i
+		}
+	}
+	return match, nil
+}
+
+// RawMessage is the encoded value of a keyed field.
+//
+// A struct field of type map[string]RawMessage tagged `memorypack:",unknown"`
+// collects the fields that the struct does not declare when decoding keyed
+// data, and writes them back when encoding, so that data from a newer version
+// of the type passes through an older one intact. The field is not encoded
+// outside keyed mode.
+type RawMessage []byte
+
+var unknownFieldsType = reflect.TypeFor[map[string]RawMessage]()
@@ -0,0 +1,201 @@
+package memorypack_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestKeyedFields tests encoding struct fields by name.
+func TestKeyedFields(t *testing.T) {
+	opts := memorypack.Options{KeyedFields: true}
+
+	type Account struct {
+		UserID int64
+		Name   string
+		Roles  []string
+		Parent *Account
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		testRoundTripWithOptions(t, Account{UserID: 1, Name: "root", Roles: []string{"admin"}}, opts)
+		testRoundTripWithOptions(t, Account{UserID: 2, Parent: &Account{UserID: 1}}, opts)
+	})
+
+	t.Run("Reordered", func(t *testing.T) {
+		type Reordered struct {
+			Roles  []string
+			Parent *Account
+			Name   string
+			UserID int64
+		}
+
+		data, err := memorypack.SerializeWithOptions(&Account{UserID: 7, Name: "n", Roles: []string{"r"}}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result Reordered
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result.UserID != 7 || result.Name != "n" || len(result.Roles) != 1 {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("ReverseOrderWithUnknown", func(t *testing.T) {
+		// Write the fields of Account in reverse order with an extra field
+		// in the middle, as a newer version of the type might.
+		writer := memorypack.NewWriter(0)
+		if err := writer.WriteObjectHeader(5); err != nil {
+			t.Fatalf("WriteObjectHeader failed: %v", err)
+		}
+		for _, field := range []struct {
+			name  string
+			value any
+		}{
+			{"Parent", (*Account)(nil)},
+			{"Roles", []string{"ops"}},
+			{"Nickname", "unused"},
+			{"Name", "rev"},
+			{"UserID", int64(9)},
+		} {
+			data, err := memorypack.SerializeWithOptions(field.value, opts)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			writer.WriteString(field.name)
+			writer.WriteBytes(data)
+		}
+
+		var result Account
+		if err := memorypack.DeserializeWithOptions(writer.GetBytes(), &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if want := (Account{UserID: 9, Name: "rev", Roles: []string{"ops"}}); !reflect.DeepEqual(result, want) {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("StrictUnknownFields", func(t *testing.T) {
+		type Newer struct {
+			UserID   int64
+			Nickname string
+			Name     string
+			Roles    []string
+			Parent   *Account
+		}
+		data, err := memorypack.SerializeWithOptions(&Newer{UserID: 3, Nickname: "typo", Name: "n"}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var lenient Account
+		if err = memorypack.DeserializeWithOptions(data, &lenient, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if lenient.UserID != 3 || lenient.Name != "n" {
+			t.Errorf("Unexpected result: %+v", lenient)
+		}
+
+		strict := opts
+		strict.StrictUnknownFields = true
+		var result Account
+		err = memorypack.DeserializeWithOptions(data, &result, strict)
+		if err == nil || err.Error() != "unknown field: Nickname" {
+			t.Errorf("Expected unknown field error, got %v", err)
+		}
+		testRoundTripWithOptions(t, Account{UserID: 4, Name: "known"}, strict)
+	})
+
+	t.Run("PreserveUnknown", func(t *testing.T) {
+		type AccountV2 struct {
+			UserID   int64
+			Name     string
+			Nickname string
+			Scores   map[string]int32
+		}
+		type AccountV1 struct {
+			UserID int64
+			Name   string
+			Extra  map[string]memorypack.RawMessage `memorypack:",unknown"`
+		}
+
+		newer := AccountV2{UserID: 3, Name: "hina", Nickname: "chair", Scores: map[string]int32{"s": 1}}
+		data, err := memorypack.SerializeWithOptions(&newer, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var older AccountV1
+		if err = memorypack.DeserializeWithOptions(data, &older, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if older.UserID != 3 || older.Name != "hina" {
+			t.Errorf("Got %+v, want the declared fields of %+v", older, newer)
+		}
+		if len(older.Extra) != 2 || older.Extra["Nickname"] == nil || older.Extra["Scores"] == nil {
+			t.Fatalf("Expected Nickname and Scores to be collected, got %v", older.Extra)
+		}
+
+		older.Name = "hina2"
+		data, err = memorypack.SerializeWithOptions(&older, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result AccountV2
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		newer.Name = "hina2"
+		if !reflect.DeepEqual(result, newer) {
+			t.Errorf("Got %+v, want %+v", result, newer)
+		}
+
+		// Without keyed fields the catch-all field is not encoded.
+		testRoundTrip(t, AccountV1{UserID: 1, Name: "plain"})
+
+		older.Extra["Name"] = memorypack.RawMessage{0}
+		if _, err = memorypack.SerializeWithOptions(&older, opts); err == nil {
+			t.Error("Expected error for an unknown field named like a declared one")
+		}
+	})
+}
+
+// TestCaseInsensitiveFields tests matching keyed field names regardless of case.
+func TestCaseInsensitiveFields(t *testing.T) {
+	type User struct {
+		UserID int64
+	}
+
+	value, err := memorypack.Serialize(int64(42))
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	writer := memorypack.NewWriter(0)
+	if err = writer.WriteObjectHeader(1); err != nil {
+		t.Fatalf("WriteObjectHeader failed: %v", err)
+	}
+	writer.WriteString("userid")
+	writer.WriteBytes(value)
+	data := writer.GetBytes()
+
+	var strict User
+	err = memorypack.DeserializeWithOptions(data, &strict, memorypack.Options{KeyedFields: true, AllowMissingFields: true})
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if strict.UserID != 0 {
+		t.Errorf("Expected userid not to match UserID by default, got %d", strict.UserID)
+	}
+
+	var folded User
+	opts := memorypack.Options{KeyedFields: true, CaseInsensitiveFields: true}
+	if err = memorypack.DeserializeWithOptions(data, &folded, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if folded.UserID != 42 {
+		t.Errorf("Expected UserID 42, got %d", folded.UserID)
+	}
+}
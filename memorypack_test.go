@@ -1,8 +1,15 @@
 package memorypack_test
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
 	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/arisu-archive/memorypack-go"
@@ -39,6 +46,14 @@ func TestBasicTypes(t *testing.T) {
 		testRoundTrip(t, int64(-9223372036854775808))
 	})
 
+	t.Run("Uint", func(t *testing.T) {
+		testRoundTrip(t, uint8(255))
+		testRoundTrip(t, uint16(65535))
+		testRoundTrip(t, uint32(4294967295))
+		testRoundTrip(t, uint64(18446744073709551615))
+		testRoundTrip(t, uint(0))
+	})
+
 	t.Run("Float32", func(t *testing.T) {
 		testRoundTrip(t, float32(0))
 		testRoundTrip(t, float32(3.14159))
@@ -113,6 +128,30 @@ func TestCollectionTypes(t *testing.T) {
 		testRoundTrip(t, [0]int{})
 	})
 
+	t.Run("LargeArray", func(t *testing.T) {
+		var large [1000]int
+		for i := range large {
+			large[i] = i*i - 500
+		}
+		testRoundTrip(t, large)
+
+		data, err := memorypack.Serialize(&large)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if want := 4 + 8*len(large); len(data) != want {
+			t.Errorf("Expected %d bytes for all elements, got %d", want, len(data))
+		}
+	})
+
+	t.Run("SliceOfArrays", func(t *testing.T) {
+		testRoundTrip(t, [][4]byte{{1, 2, 3, 4}, {}, {255, 0, 255, 0}})
+		testRoundTrip(t, [][2]string{{"a", "b"}, {"", "c"}})
+		testRoundTrip(t, [][3]int(nil))
+		testRoundTrip(t, map[string][2]float64{"p": {1.5, -2.5}})
+		testRoundTrip(t, [2][]int{{1}, nil})
+	})
+
 	t.Run("Map", func(t *testing.T) {
 		testRoundTrip(t, map[string]int(nil))
 		testRoundTrip(t, map[string]int{})
@@ -237,6 +276,104 @@ func TestPointers(t *testing.T) {
 		testRoundTrip(t, nilPtr)
 	})
 
+	t.Run("CollectionPointer", func(t *testing.T) {
+		type Holder struct {
+			Ints  *[]int32
+			Names *map[string]int32
+			After int32
+		}
+		var nilSlice []int32
+		var nilMap map[string]int32
+		emptySlice, emptyMap := []int32{}, map[string]int32{}
+		// 255 elements give a header starting with the null pointer byte.
+		fullSlice, fullMap := make([]int32, 255), map[string]int32{"a": 1}
+
+		opts := memorypack.Options{PointerMarkers: true}
+		for _, tc := range []struct {
+			name   string
+			holder Holder
+		}{
+			{"NilPointer", Holder{After: 7}},
+			{"PointerToNil", Holder{Ints: &nilSlice, Names: &nilMap, After: 7}},
+			{"PointerToEmpty", Holder{Ints: &emptySlice, Names: &emptyMap, After: 7}},
+			{"PointerToPopulated", Holder{Ints: &fullSlice, Names: &fullMap, After: 7}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				data, err := memorypack.SerializeWithOptions(&tc.holder, opts)
+				if err != nil {
+					t.Fatalf("Serialize failed: %v", err)
+				}
+				var result Holder
+				if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+					t.Fatalf("Deserialize failed: %v", err)
+				}
+
+				if (result.Ints == nil) != (tc.holder.Ints == nil) || (result.Names == nil) != (tc.holder.Names == nil) {
+					t.Fatalf("Pointer nilness not preserved: got %+v, want %+v", result, tc.holder)
+				}
+				if tc.holder.Ints != nil {
+					if (*result.Ints == nil) != (*tc.holder.Ints == nil) || len(*result.Ints) != len(*tc.holder.Ints) {
+						t.Errorf("Slice not preserved: got %v, want %v", *result.Ints, *tc.holder.Ints)
+					}
+					if (*result.Names == nil) != (*tc.holder.Names == nil) || !reflect.DeepEqual(*result.Names, *tc.holder.Names) {
+						t.Errorf("Map not preserved: got %v, want %v", *result.Names, *tc.holder.Names)
+					}
+				}
+				if result.After != 7 {
+					t.Errorf("Expected the following field to be 7, got %d", result.After)
+				}
+			})
+		}
+	})
+
+	t.Run("NullBytePointees", func(t *testing.T) {
+		// Each pointee's encoding starts with the null pointer byte.
+		type Holder struct {
+			Int    *int
+			Small  *int8
+			Text   *string
+			Nested **int32
+			Any    *any
+			Opt    *memorypack.Optional[int32]
+			After  int32
+		}
+		n, small, text := 255, int8(-1), strings.Repeat("x", 256)
+		var nilInner *int32
+		var nilAny any
+		var unset memorypack.Optional[int32]
+		original := Holder{Int: &n, Small: &small, Text: &text, Nested: &nilInner, Any: &nilAny, Opt: &unset, After: 7}
+		opts := memorypack.Options{PointerMarkers: true}
+		testRoundTripWithOptions(t, original, opts)
+		testRoundTripWithOptions(t, Holder{After: 7}, opts)
+
+		data, err := memorypack.SerializeWithOptions(&original, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result Holder
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result.Int == nil || *result.Int != 255 || result.Text == nil || *result.Text != text || result.Nested == nil || *result.Nested != nil {
+			t.Errorf("Got %+v", result)
+		}
+	})
+
+	t.Run("DefaultHasNoMarker", func(t *testing.T) {
+		type Holder struct {
+			A int32
+			B *int32
+		}
+		b := int32(5)
+		data, err := memorypack.Serialize(&Holder{A: 1, B: &b})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if want := []byte{2, 1, 0, 0, 0, 5, 0, 0, 0}; !bytes.Equal(data, want) {
+			t.Errorf("Expected %v, got %v", want, data)
+		}
+	})
+
 	type LinkedNode struct {
 		Value int
 		Next  *LinkedNode
@@ -320,7 +457,72 @@ func (c *CustomFormat) Deserialize(reader *memorypack.Reader) error {
 	return nil
 }
 
+// OptionalFormat is a Formatter with an optional nested value.
+type OptionalFormat struct {
+	Name  string
+	Limit *int32
+}
+
+func (o *OptionalFormat) Serialize(writer *memorypack.Writer) error {
+	writer.WriteString(o.Name)
+	if o.Limit == nil {
+		writer.WriteNull()
+		return nil
+	}
+	writer.WriteByte(1)
+	writer.WriteInt32(*o.Limit)
+	return nil
+}
+
+func (o *OptionalFormat) Deserialize(reader *memorypack.Reader) error {
+	name, err := reader.ReadString()
+	if err != nil {
+		return err
+	}
+	o.Name = name
+
+	if reader.IsNull() {
+		o.Limit = nil
+		return reader.ReadNull()
+	}
+	if _, err = reader.ReadByte(); err != nil {
+		return err
+	}
+	limit, err := reader.ReadInt32()
+	if err != nil {
+		return err
+	}
+	o.Limit = &limit
+	return nil
+}
+
 func TestFormatterInterface(t *testing.T) {
+	t.Run("OptionalField", func(t *testing.T) {
+		limit := int32(10)
+		for _, original := range []*OptionalFormat{{Name: "set", Limit: &limit}, {Name: "unset"}} {
+			data, err := memorypack.Serialize(original)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			result := &OptionalFormat{}
+			if err = memorypack.Deserialize(data, result); err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+			if !reflect.DeepEqual(original, result) {
+				t.Errorf("Result mismatch: got %+v, want %+v", result, original)
+			}
+		}
+
+		reader := memorypack.NewReader([]byte{1})
+		if reader.IsNull() {
+			t.Error("IsNull reported a non-null byte as null")
+		}
+		if err := reader.ReadNull(); err == nil {
+			t.Error("Expected ReadNull to fail on a non-null byte")
+		}
+	})
+
 	t.Run("CustomFormatter", func(t *testing.T) {
 		original := &CustomFormat{IntValue: 42, StrValue: "custom"}
 		data, err := memorypack.Serialize(original)
@@ -336,6 +538,73 @@ func TestFormatterInterface(t *testing.T) {
 		if !reflect.DeepEqual(original, result) {
 			t.Errorf("Result mismatch: got %+v, want %+v", result, original)
 		}
+
+		// The Formatter output is the whole encoding.
+		if want := 4 + 4 + 4 + len("custom"); len(data) != want {
+			t.Errorf("Expected %d bytes, got %d", want, len(data))
+		}
+	})
+
+	t.Run("ValueField", func(t *testing.T) {
+		type Wrapper struct {
+			Name   string
+			Custom CustomFormat
+			List   []CustomFormat
+			ByKey  map[string]CustomFormat
+			Ptr    *CustomFormat
+		}
+
+		original := Wrapper{
+			Name:   "w",
+			Custom: CustomFormat{IntValue: 1, StrValue: "one"},
+			List:   []CustomFormat{{IntValue: 2}, {StrValue: "three"}},
+			ByKey:  map[string]CustomFormat{"k": {IntValue: 4, StrValue: "four"}},
+		}
+		testRoundTrip(t, original)
+
+		// The field is written by its Formatter rather than by reflection.
+		data, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		custom := memorypack.NewWriter(0)
+		if err := original.Custom.Serialize(custom); err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if !bytes.Contains(data, custom.GetBytes()) {
+			t.Errorf("Expected Formatter output % x in % x", custom.GetBytes(), data)
+		}
+	})
+
+	t.Run("PointerMapValues", func(t *testing.T) {
+		original := map[string]*CustomFormat{
+			"a":   {IntValue: 1, StrValue: "one"},
+			"b":   {IntValue: -2},
+			"nil": nil,
+		}
+		testRoundTrip(t, original)
+
+		// Each value is its Formatter output, with no object header or
+		// int64-sized IntValue as reflection would write.
+		data, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		want := 4
+		for key, value := range original {
+			want += 8 + len(key)
+			if value == nil {
+				want++
+				continue
+			}
+			want += 4 + 4
+			if value.StrValue != "" {
+				want += 4 + len(value.StrValue)
+			}
+		}
+		if len(data) != want {
+			t.Errorf("Expected %d bytes, got %d", want, len(data))
+		}
 	})
 }
 
@@ -499,3 +768,588 @@ func TestEdgeAndCornerCases(t *testing.T) {
 		testRoundTrip(t, deep)
 	})
 }
+
+// TestDeserializePrefix tests decoding only the leading fields of a struct.
+func TestDeserializePrefix(t *testing.T) {
+	type Record struct {
+		ID    int32
+		Name  string
+		Score float64
+		Tags  []string
+		Owner *string
+	}
+
+	owner := "alice"
+	original := Record{ID: 7, Name: "preview", Score: 9.5, Tags: []string{"a", "b"}, Owner: &owner}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("TwoOfFive", func(t *testing.T) {
+		result := Record{Score: 1, Tags: []string{"stale"}}
+		decoded, offset, err := memorypack.DeserializePrefix(data, &result, 2)
+		if err != nil {
+			t.Fatalf("DeserializePrefix failed: %v", err)
+		}
+		if decoded != 2 {
+			t.Errorf("Expected 2 decoded fields, got %d", decoded)
+		}
+
+		want := Record{ID: 7, Name: "preview"}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("Result mismatch: got %+v, want %+v", result, want)
+		}
+
+		// Object header + int32 + string headers + "preview".
+		if wantOffset := 1 + 4 + 8 + len("preview"); offset != wantOffset {
+			t.Errorf("Expected offset %d, got %d", wantOffset, offset)
+		}
+	})
+
+	t.Run("MoreThanAvailable", func(t *testing.T) {
+		var result Record
+		decoded, offset, err := memorypack.DeserializePrefix(data, &result, 10)
+		if err != nil {
+			t.Fatalf("DeserializePrefix failed: %v", err)
+		}
+		if decoded != 5 || offset != len(data) {
+			t.Errorf("Expected 5 fields and offset %d, got %d and %d", len(data), decoded, offset)
+		}
+		if !reflect.DeepEqual(result, original) {
+			t.Errorf("Result mismatch: got %+v, want %+v", result, original)
+		}
+	})
+
+	t.Run("NonStruct", func(t *testing.T) {
+		var result int
+		if _, _, err := memorypack.DeserializePrefix(data, &result, 1); err == nil {
+			t.Error("Expected error for non-struct target, got nil")
+		}
+	})
+}
+
+// TestCompressedFields tests the gzip tag option on struct fields.
+func TestCompressedFields(t *testing.T) {
+	type Sprite struct {
+		Name   string
+		Width  int32
+		Pixels []byte `memorypack:"2,gzip"`
+	}
+
+	type PlainSprite struct {
+		Name   string
+		Width  int32
+		Pixels []byte
+	}
+
+	t.Run("Compressible", func(t *testing.T) {
+		pixels := make([]byte, 64*1024)
+		for i := range pixels {
+			pixels[i] = byte(i / 4096)
+		}
+		original := Sprite{Name: "hero", Width: 256, Pixels: pixels}
+
+		plain, err := memorypack.Serialize(&PlainSprite{Name: "hero", Width: 256, Pixels: pixels})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		compressed, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if len(compressed)*10 > len(plain) {
+			t.Errorf("Expected at least 10x reduction, got %d bytes vs %d plain", len(compressed), len(plain))
+		}
+
+		testRoundTrip(t, original)
+	})
+
+	t.Run("Incompressible", func(t *testing.T) {
+		testRoundTrip(t, Sprite{Name: "tiny", Pixels: []byte{1, 2, 3}})
+		testRoundTrip(t, Sprite{Name: "nil"})
+	})
+
+	t.Run("CorruptPayload", func(t *testing.T) {
+		data, err := memorypack.Serialize(&Sprite{Pixels: make([]byte, 4096)})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		data[len(data)-10] ^= 0xFF
+
+		var result Sprite
+		if err = memorypack.Deserialize(data, &result); err == nil {
+			t.Error("Expected error for corrupt compressed field, got nil")
+		}
+	})
+}
+
+// gcmCipher encrypts with AES-GCM, prefixing each ciphertext with its nonce.
+type gcmCipher struct {
+	aead cipher.AEAD
+}
+
+func newGCMCipher(t *testing.T) gcmCipher {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("NewGCM failed: %v", err)
+	}
+	return gcmCipher{aead: aead}
+}
+
+func (c gcmCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c gcmCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+// TestEncryptedFields tests encrypting tagged fields with a registered Cipher.
+func TestEncryptedFields(t *testing.T) {
+	type Credentials struct {
+		User   string
+		Token  string `memorypack:"1,encrypt"`
+		Scopes []string
+		Notes  []byte `memorypack:"3,gzip,encrypt"`
+	}
+	const secret = "s3cr3t-t0k3n"
+	original := Credentials{User: "svc", Token: secret, Scopes: []string{"read"}, Notes: make([]byte, 4096)}
+
+	memorypack.RegisterCipher(nil)
+	if _, err := memorypack.Serialize(&original); err == nil {
+		t.Error("Expected error without a registered cipher")
+	}
+
+	memorypack.RegisterCipher(newGCMCipher(t))
+	defer memorypack.RegisterCipher(nil)
+
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if bytes.Contains(data, []byte(secret)) {
+		t.Error("Encrypted field appears in plaintext")
+	}
+	if len(data) > 200 {
+		t.Errorf("Expected the zeroed notes to compress before encryption, got %d bytes", len(data))
+	}
+	testRoundTrip(t, original)
+	testRoundTrip(t, Credentials{})
+
+	// Tampering with the ciphertext fails authentication.
+	data[len(data)-1] ^= 0xFF
+	var result Credentials
+	if err = memorypack.Deserialize(data, &result); err == nil || !strings.Contains(err.Error(), "decrypt field Notes") {
+		t.Errorf("Expected decryption error, got %v", err)
+	}
+
+	// A plaintext payload for an encrypted field is rejected.
+	type Secret struct {
+		Token string `memorypack:"0,encrypt"`
+	}
+	payload := memorypack.NewWriter(16)
+	payload.WriteString("forged")
+	forged := memorypack.NewWriter(32)
+	if err = forged.WriteObjectHeader(1); err != nil {
+		t.Fatalf("WriteObjectHeader failed: %v", err)
+	}
+	forged.WriteByte(0)
+	forged.WriteBytes(payload.GetBytes())
+	var secretResult Secret
+	if err = memorypack.Deserialize(forged.GetBytes(), &secretResult); err == nil || !strings.Contains(err.Error(), "encrypted=false") {
+		t.Errorf("Expected a plaintext Token to be rejected, got %v (Token %q)", err, secretResult.Token)
+	}
+}
+
+// TestDeserializeWithDefaults tests decoding old data into a struct with added fields.
+func TestDeserializeWithDefaults(t *testing.T) {
+	type SettingsV1 struct {
+		Name    string
+		Retries int32
+	}
+	type SettingsV2 struct {
+		Name    string
+		Retries int32
+		Timeout int64
+		Tags    []string
+	}
+
+	data, err := memorypack.Serialize(&SettingsV1{Name: "svc", Retries: 3})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("StrictByDefault", func(t *testing.T) {
+		var result SettingsV2
+		if err := memorypack.Deserialize(data, &result); err == nil {
+			t.Error("Expected field count mismatch, got nil")
+		}
+	})
+
+	t.Run("AllowMissingFields", func(t *testing.T) {
+		result := SettingsV2{Timeout: 99}
+		err := memorypack.DeserializeWithOptions(data, &result, memorypack.Options{AllowMissingFields: true})
+		if err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if want := (SettingsV2{Name: "svc", Retries: 3}); !reflect.DeepEqual(result, want) {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		var result SettingsV2
+		err := memorypack.DeserializeWithDefaults(data, &result, func(name string) (any, bool) {
+			if name == "Timeout" {
+				return int64(30), true
+			}
+			return nil, false
+		})
+		if err != nil {
+			t.Fatalf("DeserializeWithDefaults failed: %v", err)
+		}
+		if want := (SettingsV2{Name: "svc", Retries: 3, Timeout: 30}); !reflect.DeepEqual(result, want) {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("WrongDefaultType", func(t *testing.T) {
+		var result SettingsV2
+		err := memorypack.DeserializeWithDefaults(data, &result, func(string) (any, bool) {
+			return "thirty", true
+		})
+		if err == nil {
+			t.Error("Expected error for a default of the wrong type, got nil")
+		}
+	})
+}
+
+// TestDeserializeReset tests decoding into a reused object without keeping stale fields.
+func TestDeserializeReset(t *testing.T) {
+	type Message struct {
+		ID      int64
+		Body    string
+		Headers map[string]string
+		Retry   *int32
+	}
+	opts := memorypack.Options{FieldPresence: true}
+	data, err := memorypack.SerializeWithOptions(&Message{ID: 2}, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	retry := int32(3)
+	dirty := func() Message {
+		return Message{ID: 1, Body: "old", Headers: map[string]string{"k": "v"}, Retry: &retry}
+	}
+
+	pooled := dirty()
+	if err = memorypack.DeserializeWithOptions(data, &pooled, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if pooled.Headers["k"] != "v" || pooled.Body != "" {
+		t.Fatalf("Expected absent fields to keep stale values without a reset, got %+v", pooled)
+	}
+
+	pooled = dirty()
+	if err = memorypack.DeserializeReset(data, &pooled, opts); err != nil {
+		t.Fatalf("DeserializeReset failed: %v", err)
+	}
+	if want := (Message{ID: 2}); !reflect.DeepEqual(pooled, want) {
+		t.Errorf("Got %+v, want %+v", pooled, want)
+	}
+
+	if err = memorypack.DeserializeReset[Message](data, nil, opts); err == nil {
+		t.Error("Expected error for a nil pointer")
+	}
+}
+
+// TestDeserializeWithProgress tests progress reporting while decoding collections.
+func TestDeserializeWithProgress(t *testing.T) {
+	type Chunk struct {
+		ID      int32
+		Payload []byte
+	}
+	original := map[string][]Chunk{}
+	for i := range 20 {
+		original["file"+strconv.Itoa(i)] = []Chunk{{ID: int32(i), Payload: make([]byte, 64)}}
+	}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var reports []int
+	var result map[string][]Chunk
+	err = memorypack.DeserializeWithProgress(data, &result, func(bytesRead int) {
+		reports = append(reports, bytesRead)
+	})
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Error("Result mismatch")
+	}
+
+	if len(reports) < len(original) {
+		t.Fatalf("Expected at least %d reports, got %d", len(original), len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] <= reports[i-1] {
+			t.Fatalf("Progress went from %d to %d", reports[i-1], reports[i])
+		}
+	}
+	if last := reports[len(reports)-1]; last != len(data) {
+		t.Errorf("Expected final report of %d bytes, got %d", len(data), last)
+	}
+}
+
+// TestSerializeFields tests projecting a subset of struct fields.
+func TestSerializeFields(t *testing.T) {
+	type Person struct {
+		Name    string
+		Age     int32
+		Email   string
+		Friends []string
+	}
+
+	original := Person{Name: "Ann", Age: 33, Email: "ann@example.com", Friends: []string{"Bo"}}
+	data, err := memorypack.SerializeFields(&original, []string{"Age", "Name"})
+	if err != nil {
+		t.Fatalf("SerializeFields failed: %v", err)
+	}
+	if bytes.Contains(data, []byte("example.com")) {
+		t.Error("Excluded field found in output")
+	}
+
+	result := Person{Email: "stale"}
+	if err = memorypack.DeserializeFields(data, &result); err != nil {
+		t.Fatalf("DeserializeFields failed: %v", err)
+	}
+	if want := (Person{Name: "Ann", Age: 33}); !reflect.DeepEqual(result, want) {
+		t.Errorf("Got %+v, want %+v", result, want)
+	}
+
+	if _, err = memorypack.SerializeFields(original, []string{"Phone"}); err == nil {
+		t.Error("Expected error for unknown field, got nil")
+	}
+}
+
+// TestPointerToValueField tests decoding data written for a pointer field into
+// a field that has since become a value.
+func TestPointerToValueField(t *testing.T) {
+	type Address struct {
+		Street string
+		Number int32
+	}
+	type PersonV1 struct {
+		Name    string
+		Address *Address
+	}
+	type PersonV2 struct {
+		Name    string
+		Address Address
+	}
+
+	for _, opts := range []memorypack.Options{{}, {TrackReferences: true}} {
+		for _, original := range []PersonV1{
+			{Name: "set", Address: &Address{Street: "Main", Number: 1}},
+			{Name: "nil"},
+		} {
+			data, err := memorypack.SerializeWithOptions(&original, opts)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			result := PersonV2{Address: Address{Street: "stale"}}
+			if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+				t.Fatalf("Deserialize failed: %v", err)
+			}
+
+			want := PersonV2{Name: original.Name}
+			if original.Address != nil {
+				want.Address = *original.Address
+			}
+			if result != want {
+				t.Errorf("Options %+v: got %+v, want %+v", opts, result, want)
+			}
+		}
+	}
+
+	t.Run("WideStruct", func(t *testing.T) {
+		// A header of 250 fields would read as a reference, so structs with
+		// that many fields are rejected, including plain-old-data ones.
+		fields := make([]reflect.StructField, 250)
+		for i := range fields {
+			fields[i] = reflect.StructField{Name: "F" + strconv.Itoa(i), Type: reflect.TypeFor[int8]()}
+		}
+		wide := reflect.New(reflect.StructOf(fields))
+		for _, opts := range []memorypack.Options{{}, {TrackReferences: true}} {
+			if _, err := memorypack.SerializeWithOptions(wide.Interface(), opts); err == nil {
+				t.Errorf("TrackReferences %t: expected error for 250 fields", opts.TrackReferences)
+			}
+		}
+
+		var result PersonV2
+		data := append([]byte{2, 0, 0, 0, 0}, 250)
+		if err := memorypack.Deserialize(data, &result); !errors.Is(err, memorypack.ErrInvalidHeader) {
+			t.Errorf("Expected ErrInvalidHeader for object header 250, got %v", err)
+		}
+	})
+}
+
+// TestDeserializeValue tests decoding into a settable reflect.Value.
+func TestDeserializeValue(t *testing.T) {
+	type Record struct {
+		ID   int32
+		Tags []string
+	}
+
+	original := Record{ID: 9, Tags: []string{"a", "b"}}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	v := reflect.New(reflect.TypeFor[Record]()).Elem()
+	if err = memorypack.DeserializeValue(data, v); err != nil {
+		t.Fatalf("DeserializeValue failed: %v", err)
+	}
+	if result := v.Interface().(Record); !reflect.DeepEqual(result, original) {
+		t.Errorf("Got %+v, want %+v", result, original)
+	}
+
+	if err = memorypack.DeserializeValue(data, reflect.ValueOf(original)); err == nil {
+		t.Error("Expected error for a value that is not settable")
+	}
+}
+
+// TestSerializeValue tests composing a message from several values.
+func TestSerializeValue(t *testing.T) {
+	type Header struct {
+		Kind int16
+		Name string
+	}
+
+	header := Header{Kind: 3, Name: "batch"}
+	items := []int64{10, 20, 30}
+
+	writer := memorypack.NewWriter(64)
+	if err := writer.WriteObjectHeader(2); err != nil {
+		t.Fatalf("WriteObjectHeader failed: %v", err)
+	}
+	for _, v := range []any{header, items} {
+		if err := memorypack.SerializeValue(writer, reflect.ValueOf(v)); err != nil {
+			t.Fatalf("SerializeValue failed: %v", err)
+		}
+	}
+
+	// The composed message matches a struct with the same two fields.
+	var result struct {
+		Header Header
+		Items  []int64
+	}
+	if err := memorypack.Deserialize(writer.GetBytes(), &result); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if result.Header != header || !reflect.DeepEqual(result.Items, items) {
+		t.Errorf("Got %+v", result)
+	}
+
+	if err := memorypack.SerializeValue(writer, reflect.Value{}); err == nil {
+		t.Error("Expected error for an invalid value")
+	}
+}
+
+// transientConfig holds fields excluded at runtime by its Transient method.
+type transientConfig struct {
+	Name    string
+	Debug   string
+	Secret  string
+	Retries int32
+}
+
+var transientConfigFields []string
+
+func (*transientConfig) Transient() []string { return transientConfigFields }
+
+// TestTransientFields tests excluding fields listed by a Transient method.
+func TestTransientFields(t *testing.T) {
+	original := transientConfig{Name: "svc", Debug: "trace", Secret: "hunter2", Retries: 3}
+	roundTrip := func(excluded ...string) transientConfig {
+		t.Helper()
+		transientConfigFields = excluded
+		defer func() { transientConfigFields = nil }()
+		data, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if data[0] != byte(4-len(excluded)) {
+			t.Errorf("Expected %d fields, got header %d", 4-len(excluded), data[0])
+		}
+		schema, err := memorypack.NewSchema(&original)
+		if err != nil {
+			t.Fatalf("NewSchema failed: %v", err)
+		}
+		if err = schema.Validate(data); err != nil {
+			t.Errorf("Validate failed: %v", err)
+		}
+		var result transientConfig
+		if err = memorypack.Deserialize(data, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		return result
+	}
+
+	if result := roundTrip(); result != original {
+		t.Errorf("Got %+v, want %+v", result, original)
+	}
+	if result := roundTrip("Secret"); result != (transientConfig{Name: "svc", Debug: "trace", Retries: 3}) {
+		t.Errorf("Expected Secret to be excluded, got %+v", result)
+	}
+	if result := roundTrip("Debug", "Secret"); result != (transientConfig{Name: "svc", Retries: 3}) {
+		t.Errorf("Expected Debug and Secret to be excluded, got %+v", result)
+	}
+
+	// Validate caches the schema, calling Transient once.
+	transientConfigFields = []string{"Secret"}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if err = memorypack.Validate(data, &transientConfig{}); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+
+	// Transient applies to nested values and alongside FieldFilter.
+	type Outer struct {
+		Configs []transientConfig
+	}
+	transientConfigFields = []string{"Debug"}
+	defer func() { transientConfigFields = nil }()
+	opts := memorypack.Options{FieldFilter: func(_ reflect.Type, name string) bool { return name != "Retries" }}
+	data, err = memorypack.SerializeWithOptions(&Outer{Configs: []transientConfig{original}}, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var result Outer
+	if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if want := (transientConfig{Name: "svc", Secret: "hunter2"}); len(result.Configs) != 1 || result.Configs[0] != want {
+		t.Errorf("Got %+v, want [%+v]", result.Configs, want)
+	}
+}
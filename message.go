@@ -0,0 +1,62 @@
+package memorypack
+
+import (
+	"fmt"
+	"io"
+)
+
+// SerializedMessage holds the serialized bytes of a value.
+//
+// It implements io.Reader and io.WriterTo so that results can be passed
+// directly to io.Copy, net/http response writers and similar APIs.
+type SerializedMessage struct {
+	data []byte
+	off  int
+}
+
+// SerializeMessage serializes a value into a SerializedMessage.
+func SerializeMessage(value any) (*SerializedMessage, error) {
+	data, err := Serialize(value)
+	if err != nil {
+		return nil, err
+	}
+	return &SerializedMessage{data: data}, nil
+}
+
+// Bytes returns the serialized bytes that have not been read yet.
+func (m *SerializedMessage) Bytes() []byte {
+	return m.data[m.off:]
+}
+
+// Len returns the number of bytes that have not been read yet.
+func (m *SerializedMessage) Len() int {
+	return len(m.data) - m.off
+}
+
+// Read reads the next len(p) bytes of the message into p.
+func (m *SerializedMessage) Read(p []byte) (int, error) {
+	if m.off >= len(m.data) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, m.data[m.off:])
+	m.off += n
+	return n, nil
+}
+
+// WriteTo writes the unread bytes of the message to w.
+func (m *SerializedMessage) WriteTo(w io.Writer) (int64, error) {
+	remaining := m.data[m.off:]
+	n, err := w.Write(remaining)
+	m.off += n
+	if err != nil {
+		return int64(n), fmt.Errorf("write serialized message: %w", err)
+	}
+	if n != len(remaining) {
+		return int64(n), io.ErrShortWrite
+	}
+	return int64(n), nil
+}
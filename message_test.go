@@ -0,0 +1,122 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestSerializedMessage tests the io integration of SerializedMessage.
+func TestSerializedMessage(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+		Tags []string
+	}
+
+	original := Person{Name: "Alice", Age: 30, Tags: []string{"admin", "ops"}}
+	want, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("WriterTo", func(t *testing.T) {
+		msg, err := memorypack.SerializeMessage(&original)
+		if err != nil {
+			t.Fatalf("SerializeMessage failed: %v", err)
+		}
+
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, msg)
+		if err != nil {
+			t.Fatalf("io.Copy failed: %v", err)
+		}
+		if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("Copied bytes mismatch: got %x, want %x", buf.Bytes(), want)
+		}
+		if msg.Len() != 0 {
+			t.Errorf("Expected message to be drained, %d bytes left", msg.Len())
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		msg, err := memorypack.SerializeMessage(&original)
+		if err != nil {
+			t.Fatalf("SerializeMessage failed: %v", err)
+		}
+
+		got, err := io.ReadAll(msg)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Read bytes mismatch: got %x, want %x", got, want)
+		}
+
+		var result Person
+		if err = memorypack.Deserialize(got, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result.Name != original.Name || result.Age != original.Age {
+			t.Errorf("Result mismatch: got %+v, want %+v", result, original)
+		}
+	})
+}
+
+// TestSerializeTo tests serializing directly to an io.Writer.
+func TestSerializeTo(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+
+	for _, value := range []any{
+		&Person{Name: "Bob", Age: 40},
+		[]byte{1, 2, 3},
+		&[]byte{4, 5},
+		[]byte{},
+		[]byte(nil),
+	} {
+		want, err := memorypack.Serialize(value)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err = memorypack.SerializeTo(&buf, value); err != nil {
+			t.Fatalf("SerializeTo failed: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("SerializeTo(%v) wrote %v, want %v", value, buf.Bytes(), want)
+		}
+	}
+}
+
+// BenchmarkSerializeTo compares buffered and direct serialization of a 50MB byte slice.
+func BenchmarkSerializeTo(b *testing.B) {
+	payload := bytes.Repeat([]byte{0xAB}, 50<<20)
+
+	b.Run("Buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			data, err := memorypack.Serialize(payload)
+			if err != nil {
+				b.Fatalf("Serialize failed: %v", err)
+			}
+			if _, err = io.Discard.Write(data); err != nil {
+				b.Fatalf("Write failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("Direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if err := memorypack.SerializeTo(io.Discard, payload); err != nil {
+				b.Fatalf("SerializeTo failed: %v", err)
+			}
+		}
+	})
+}
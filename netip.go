@@ -0,0 +1,88 @@
+package memorypack
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+)
+
+var (
+	addrType     = reflect.TypeFor[netip.Addr]()
+	addrPortType = reflect.TypeFor[netip.AddrPort]()
+)
+
+// writeAddr writes an IP address as a byte holding its length, 0, 4 or 16,
+// followed by the address in network order and, for IPv6, its zone.
+func writeAddr(writer *Writer, addr netip.Addr) {
+	switch {
+	case addr.Is4():
+		b := addr.As4()
+		writer.WriteByte(4)
+		writeRawBytes(writer, b[:])
+	case addr.Is6():
+		b := addr.As16()
+		writer.WriteByte(16)
+		writeRawBytes(writer, b[:])
+		writer.WriteString(addr.Zone())
+	default:
+		writer.WriteByte(0)
+	}
+}
+
+// writeRawBytes writes b without a length header.
+func writeRawBytes(writer *Writer, b []byte) {
+	writer.ensureCapacity(len(b))
+	writer.pos += copy(writer.buffer[writer.pos:], b)
+}
+
+// readAddr reads an IP address written by writeAddr.
+func readAddr(reader *Reader) (netip.Addr, error) {
+	size, err := reader.ReadByte()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	switch size {
+	case 0:
+		return netip.Addr{}, nil
+	case 4:
+		if !reader.ensure(4) {
+			return netip.Addr{}, fmt.Errorf("cannot read IPv4 address: %w", ErrTruncated)
+		}
+		addr := netip.AddrFrom4([4]byte(reader.buffer[reader.pos:]))
+		reader.pos += 4
+		return addr, nil
+	case 16:
+		if !reader.ensure(16) {
+			return netip.Addr{}, fmt.Errorf("cannot read IPv6 address: %w", ErrTruncated)
+		}
+		addr := netip.AddrFrom16([16]byte(reader.buffer[reader.pos:]))
+		reader.pos += 16
+		zone, err := reader.ReadString()
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		return addr.WithZone(zone), nil
+	default:
+		return netip.Addr{}, fmt.Errorf("%w: IP address length %d", ErrInvalidHeader, size)
+	}
+}
+
+// writeAddrPort writes an address and port as the address followed by the
+// port as a uint16.
+func writeAddrPort(writer *Writer, ap netip.AddrPort) {
+	writeAddr(writer, ap.Addr())
+	writer.WriteInt16(int16(ap.Port()))
+}
+
+// readAddrPort reads an address and port written by writeAddrPort.
+func readAddrPort(reader *Reader) (netip.AddrPort, error) {
+	addr, err := readAddr(reader)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	port, err := reader.ReadInt16()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return netip.AddrPortFrom(addr, uint16(port)), nil
+}
@@ -0,0 +1,75 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Optional holds a value that may be unset, giving value types such as int a
+// null state distinct from their zero value.
+//
+// An unset Optional is encoded as a null object header. A set one is encoded
+// as an object header with one member followed by Value, matching the layout
+// of Nullable<T> in MemoryPack for .NET.
+type Optional[T any] struct {
+	Value T
+	Valid bool
+}
+
+// Some returns a set Optional holding value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Value: value, Valid: true}
+}
+
+// Get returns the value and whether it is set.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Valid
+}
+
+// isOptional marks instantiations of Optional for reflection.
+func (Optional[T]) isOptional() {}
+
+type optional interface{ isOptional() }
+
+var optionalInterface = reflect.TypeOf((*optional)(nil)).Elem()
+
+// isOptionalType reports whether t is an instantiation of Optional.
+func isOptionalType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(optionalInterface)
+}
+
+// writeOptional writes v if its type is an instantiation of Optional.
+func writeOptional(writer *Writer, v reflect.Value) (bool, error) {
+	if !isOptionalType(v.Type()) {
+		return false, nil
+	}
+	if !v.Field(1).Bool() {
+		writer.WriteByte(NullObject)
+		return true, nil
+	}
+	writer.WriteByte(1)
+	return true, writeValue(writer, v.Field(0))
+}
+
+// readOptional reads v if its type is an instantiation of Optional.
+func readOptional(reader *Reader, v reflect.Value) (bool, error) {
+	if !isOptionalType(v.Type()) {
+		return false, nil
+	}
+	count, isNull, err := reader.ReadObjectHeader()
+	if err != nil {
+		return true, err
+	}
+	if isNull {
+		v.Set(reflect.Zero(v.Type()))
+		return true, nil
+	}
+	if count != 1 {
+		return true, fmt.Errorf("optional %s has member count %d, expected 1", v.Type(), count)
+	}
+	if err = readValue(reader, v.Field(0)); err != nil {
+		return true, err
+	}
+	v.Field(1).SetBool(true)
+	return true, nil
+}
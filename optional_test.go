@@ -0,0 +1,76 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestOptional tests round-tripping set and unset Optional values.
+func TestOptional(t *testing.T) {
+	type Point struct {
+		X, Y int32
+	}
+	type Profile struct {
+		Name  string
+		Age   memorypack.Optional[int]
+		Email memorypack.Optional[string]
+		Home  memorypack.Optional[Point]
+	}
+
+	t.Run("Int", func(t *testing.T) {
+		testRoundTrip(t, memorypack.Some(0))
+		testRoundTrip(t, memorypack.Some(255))
+		testRoundTrip(t, memorypack.Optional[int]{})
+	})
+
+	t.Run("String", func(t *testing.T) {
+		testRoundTrip(t, memorypack.Some(""))
+		testRoundTrip(t, memorypack.Some("hello"))
+		testRoundTrip(t, memorypack.Optional[string]{})
+	})
+
+	t.Run("Struct", func(t *testing.T) {
+		testRoundTrip(t, memorypack.Some(Point{X: 1, Y: -1}))
+		testRoundTrip(t, memorypack.Optional[Point]{})
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		testRoundTrip(t, Profile{Name: "unset"})
+		testRoundTrip(t, Profile{
+			Name:  "set",
+			Age:   memorypack.Some(0),
+			Email: memorypack.Some(""),
+			Home:  memorypack.Some(Point{}),
+		})
+		testRoundTrip(t, []memorypack.Optional[int]{memorypack.Some(1), {}, memorypack.Some(-1)})
+	})
+
+	t.Run("Encoding", func(t *testing.T) {
+		unset, err := memorypack.Serialize(&memorypack.Optional[int]{})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if !bytes.Equal(unset, []byte{memorypack.NullObject}) {
+			t.Errorf("unset optional encoded as %x, want %x", unset, memorypack.NullObject)
+		}
+
+		set, err := memorypack.Serialize(&memorypack.Optional[int32]{Value: 7, Valid: true})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if want := []byte{1, 7, 0, 0, 0}; !bytes.Equal(set, want) {
+			t.Errorf("set optional encoded as %x, want %x", set, want)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		if value, ok := memorypack.Some(3).Get(); !ok || value != 3 {
+			t.Errorf("Get() = %d, %v, want 3, true", value, ok)
+		}
+		if _, ok := (memorypack.Optional[int]{}).Get(); ok {
+			t.Error("Get() reported an unset optional as set")
+		}
+	})
+}
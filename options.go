@@ -0,0 +1,214 @@
+package memorypack
+
+import (
+	"reflect"
+	"time"
+)
+
+// Options configures optional encoding and decoding behavior.
+//
+// The zero value produces the standard MemoryPack wire format. Data written
+// with non-default options must be read back with the same options.
+type Options struct {
+	// CompactMapKeys encodes signed integer map keys as zigzag varints.
+	CompactMapKeys bool
+
+	// TaggedPrimitives prefixes every bool, integer and float with a tag byte
+	// describing its kind and width, so that decoding into a target of a
+	// different width fails with a clear error instead of misreading the stream.
+	TaggedPrimitives bool
+
+	// TrackReferences writes each distinct pointer once and encodes repeated
+	// occurrences as back-references, preserving shared and cyclic pointers.
+	// Pointers back to the top-level value decode as the pointer passed to
+	// Deserialize.
+	TrackReferences bool
+
+	// ShareSlices writes slices with the same backing array, start and length
+	// once, and encodes repeated occurrences as back-references, so that
+	// aliased slices decode sharing one backing array. Each slice is prefixed
+	// with a one-byte header.
+	ShareSlices bool
+
+	// TupleMode omits the object header of structs and writes their fields
+	// back to back, leaving the field count implied by the type. A non-nil
+	// pointer to a struct is preceded by a one-byte presence marker instead,
+	// so that it stays distinct from nil. Tuple data is not compatible with
+	// the standard MemoryPack format. It has no effect with KeyedFields.
+	TupleMode bool
+
+	// FieldPresence writes a bitmap after each struct header marking the
+	// fields that are present, and writes only those fields. Nil pointers,
+	// slices, maps, interfaces and funcs and unset Optionals are absent; other
+	// fields are present even when they hold their zero value. Decoding
+	// leaves absent fields unchanged, so a target prefilled with defaults
+	// keeps them where the sender had no value, while explicit zero values
+	// overwrite them. The decoder must use the same option.
+	FieldPresence bool
+
+	// PointerMarkers precedes the value of each non-nil pointer with a
+	// one-byte presence marker when the value could otherwise start with the
+	// null byte, as a nil slice, a negative integer or a long string can. By
+	// default such pointers may decode as nil: a pointer to a nil slice or
+	// map always does. The decoder must use the same option.
+	PointerMarkers bool
+
+	// ZeroStructs writes structs whose fields all hold their zero value as a
+	// single marker byte, which decodes back to the zero value. It has no
+	// effect with TupleMode.
+	ZeroStructs bool
+
+	// StrictArrayLength makes decoding into a Go array fail when the stream
+	// holds a different number of elements. By default, missing elements are
+	// set to zero and extra elements are skipped.
+	StrictArrayLength bool
+
+	// PackBools encodes []bool values as bitsets using one bit per element.
+	// Packed slices are decoded whether or not the reader sets this option.
+	PackBools bool
+
+	// RejectDuplicateKeys makes decoding fail when a map contains the same key
+	// more than once, instead of keeping the last value. Encoding is unaffected.
+	RejectDuplicateKeys bool
+
+	// TimeEncoding selects the layout of time.Time values. The C# DateTime and
+	// DateTimeOffset layouts interoperate with MemoryPack for .NET.
+	TimeEncoding TimeEncoding
+
+	// TimeLayout is the layout of times written with TimeEncodingString, as
+	// accepted by time.Time.Format. The default is time.RFC3339.
+	TimeLayout string
+
+	// TimePrecision sets the resolution of time.Time values written with
+	// TimeEncodingUnix. The zero value keeps full nanosecond resolution.
+	TimePrecision TimePrecision
+
+	// TimeZoneNames writes the IANA name of the location of each time.Time
+	// after its instant, and restores the location with time.LoadLocation when
+	// decoding, so that times keep their zone rather than only their offset.
+	TimeZoneNames bool
+
+	// AllowMissingFields accepts structs written with fewer fields than the
+	// target declares, as produced by an older version of the type. The
+	// missing trailing fields are set to their zero values.
+	AllowMissingFields bool
+
+	// KeyedFields writes each struct field together with its name and length,
+	// so that data can be decoded after fields are reordered or added; fields
+	// the target does not declare are skipped. Keyed data is not
+	// compatible with the standard MemoryPack format.
+	KeyedFields bool
+
+	// StrictUnknownFields makes decoding keyed data fail on a field the target
+	// does not declare, instead of skipping it. Fields collected by a field
+	// tagged ",unknown" are still accepted.
+	StrictUnknownFields bool
+
+	// CaseInsensitiveFields matches field names in keyed data to struct fields
+	// regardless of case.
+	CaseInsensitiveFields bool
+
+	// FieldFilter, if set, is called for each field of each struct type and
+	// excludes the field when it returns false. It must give the same answers
+	// when decoding as it did when encoding.
+	FieldFilter func(structType reflect.Type, fieldName string) bool
+
+	// RejectNonFinite makes serialization fail on NaN and infinite floats,
+	// reporting the path of the offending value.
+	RejectNonFinite bool
+
+	// TypeIDPrefix prefixes top-level values with the uint32 type ID they are
+	// registered under with RegisterType, so that a receiver can dispatch on
+	// PeekType before decoding. Data with a prefix can also be decoded into a
+	// pointer to an interface, which receives a pointer to the registered type.
+	TypeIDPrefix bool
+
+	// DeltaInts encodes []int and []int64 values as their first element
+	// followed by the varint difference between consecutive elements, which is
+	// much smaller for sorted data. The decoder must use the same option.
+	DeltaInts bool
+
+	// InvokeFuncs encodes fields of type func() T by calling them and writing
+	// the result. Decoding sets them to a func returning the decoded value.
+	// This is lossy: the func itself, and any state it reads on later calls,
+	// is not preserved.
+	InvokeFuncs bool
+
+	// CanonicalMaps writes map entries in key order, so that equal maps always
+	// encode to the same bytes. Integer and float keys are ordered
+	// numerically and string keys lexically; other key types are rejected.
+	CanonicalMaps bool
+
+	// DenseIntMaps writes maps with integer keys in [0, n) as a bitmap of the
+	// keys present followed by their values in key order, when that is
+	// smaller than writing each key. Other maps are written as key/value
+	// pairs. Each map records which layout it uses, but the decoder must use
+	// the same option.
+	DenseIntMaps bool
+
+	// MaxStringLength, if positive, rejects strings longer than this many
+	// bytes during decoding, before they are allocated.
+	MaxStringLength int
+
+	// CompactStrings writes strings shorter than 128 bytes with a one-byte
+	// length header instead of the standard eight-byte header. Each string
+	// records which header it uses, but the decoder must use the same option.
+	CompactStrings bool
+
+	// TagName sets the struct tag key that field order and field options are
+	// read from. The default is "memorypack".
+	TagName string
+
+	// FieldOrder selects the order struct fields are written in. The default
+	// orders fields by their tag.
+	FieldOrder FieldOrder
+
+	// MaxAllocBytes, if positive, limits the bytes a single decode may
+	// allocate for strings, slices, maps and pointers, so that a small payload
+	// cannot expand into huge structures.
+	MaxAllocBytes int64
+
+	// LenientNumeric, together with TaggedPrimitives, decodes integers
+	// written with a different width or signedness than the target, such as
+	// an int16 field later widened to int32. Values that do not fit in the
+	// target are rejected.
+	LenientNumeric bool
+
+	// ReinterpretSignedness, together with TaggedPrimitives, decodes integers
+	// written with the other signedness but the same width as the target by
+	// reinterpreting their bits, as when a uint32 field becomes an int32, so
+	// that 0xFFFFFFFF decodes as -1. By default the mismatch is an error. It
+	// takes precedence over LenientNumeric for integers of the same width.
+	ReinterpretSignedness bool
+}
+
+// FieldOrder selects the order in which struct fields are written.
+type FieldOrder byte
+
+const (
+	// FieldOrderTag orders fields by the number in their struct tag, falling
+	// back to their position in the declaration.
+	FieldOrderTag FieldOrder = iota
+	// FieldOrderDeclaration orders fields as they are declared, ignoring tags.
+	FieldOrderDeclaration
+	// FieldOrderReverse orders fields in reverse declaration order.
+	FieldOrderReverse
+	// FieldOrderAlphabetical orders fields by name.
+	FieldOrderAlphabetical
+)
+
+// timeLayout returns the layout of times written with TimeEncodingString.
+func (o *Options) timeLayout() string {
+	if o.TimeLayout == "" {
+		return time.RFC3339
+	}
+	return o.TimeLayout
+}
+
+// tagName returns the struct tag key to read field descriptions from.
+func (o *Options) tagName() string {
+	if o.TagName == "" {
+		return defaultTagName
+	}
+	return o.TagName
+}
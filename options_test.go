@@ -0,0 +1,1261 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// testRoundTripWithOptions serializes and deserializes a value using the given options.
+func testRoundTripWithOptions[T any](t *testing.T, original T, opts memorypack.Options) []byte {
+	t.Helper()
+
+	data, err := memorypack.SerializeWithOptions(&original, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var result T
+	if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(original, result) {
+		t.Errorf("Result mismatch: got %+v, want %+v", result, original)
+	}
+	return data
+}
+
+// TestCompactMapKeys tests zigzag varint encoding of integer map keys.
+func TestCompactMapKeys(t *testing.T) {
+	opts := memorypack.Options{CompactMapKeys: true}
+
+	t.Run("SmallKeys", func(t *testing.T) {
+		original := map[int]int{}
+		for i := -50; i < 50; i++ {
+			original[i] = i * 10
+		}
+
+		plain, err := memorypack.Serialize(&original)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		compact := testRoundTripWithOptions(t, original, opts)
+
+		// Each key shrinks from 8 bytes to a single varint byte.
+		if want := len(plain) - len(original)*7; len(compact) != want {
+			t.Errorf("Expected %d bytes, got %d (plain %d)", want, len(compact), len(plain))
+		}
+	})
+
+	t.Run("KeyWidths", func(t *testing.T) {
+		testRoundTripWithOptions(t, map[int16]string{-32768: "min", 0: "zero", 32767: "max"}, opts)
+		testRoundTripWithOptions(t, map[int32]bool{-1: true, 1 << 30: false}, opts)
+		testRoundTripWithOptions(t, map[int64]int{-1 << 63: 1, 1<<63 - 1: 2}, opts)
+	})
+
+	t.Run("NonIntegerKeys", func(t *testing.T) {
+		testRoundTripWithOptions(t, map[string]int{"a": 1, "b": -2}, opts)
+	})
+}
+
+// TestTaggedPrimitives tests width checking of tagged primitives.
+func TestTaggedPrimitives(t *testing.T) {
+	opts := memorypack.Options{TaggedPrimitives: true}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		type Mixed struct {
+			A bool
+			B int8
+			C int16
+			D int32
+			E int64
+			F int
+			G float32
+			H float64
+			I []int32
+			J map[int16]float32
+		}
+		testRoundTripWithOptions(t, Mixed{
+			A: true, B: -1, C: 2, D: -3, E: 4, F: -5, G: 6.5, H: -7.25,
+			I: []int32{1, 2}, J: map[int16]float32{3: 4},
+		}, opts)
+	})
+
+	t.Run("WidthMismatch", func(t *testing.T) {
+		values := []any{int8(1), int16(1), int32(1), int64(1), float32(1), float64(1), true}
+		names := []string{"1-byte int", "2-byte int", "4-byte int", "8-byte int", "4-byte float", "8-byte float", "bool"}
+
+		for i, value := range values {
+			data, err := memorypack.SerializeWithOptions(value, opts)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+
+			for j, target := range values {
+				if i == j {
+					continue
+				}
+				ptr := reflect.New(reflect.TypeOf(target))
+				err = memorypack.DeserializeWithOptions(data, ptr.Interface(), opts)
+				want := "primitive mismatch: expected " + names[j] + ", stream has " + names[i]
+				if err == nil || err.Error() != want {
+					t.Errorf("Decoding %T into %T: got error %v, want %q", value, target, err, want)
+				}
+			}
+		}
+	})
+
+	t.Run("StructFieldMismatch", func(t *testing.T) {
+		type V1 struct{ Count int32 }
+		type V2 struct{ Count int64 }
+
+		data, err := memorypack.SerializeWithOptions(&V1{Count: 7}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result V2
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err == nil {
+			t.Error("Expected width mismatch error, got nil")
+		}
+	})
+}
+
+// TestLenientNumeric tests widening integers to a wider target field.
+func TestLenientNumeric(t *testing.T) {
+	opts := memorypack.Options{TaggedPrimitives: true, LenientNumeric: true}
+
+	type V1 struct {
+		Count int16
+		Delta int8
+		Flags uint8
+	}
+	type V2 struct {
+		Count int32
+		Delta int32
+		Flags uint32
+	}
+	type V3 struct {
+		Count int64
+		Delta int64
+		Flags int64
+	}
+
+	data, err := memorypack.SerializeWithOptions(&V1{Count: -1234, Delta: -5, Flags: 200}, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var v2 V2
+	if err = memorypack.DeserializeWithOptions(data, &v2, opts); err != nil {
+		t.Fatalf("Deserialize into V2 failed: %v", err)
+	}
+	if v2 != (V2{Count: -1234, Delta: -5, Flags: 200}) {
+		t.Errorf("Got %+v", v2)
+	}
+
+	data, err = memorypack.SerializeWithOptions(&v2, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var v3 V3
+	if err = memorypack.DeserializeWithOptions(data, &v3, opts); err != nil {
+		t.Fatalf("Deserialize into V3 failed: %v", err)
+	}
+	if v3 != (V3{Count: -1234, Delta: -5, Flags: 200}) {
+		t.Errorf("Got %+v", v3)
+	}
+
+	t.Run("Narrowing", func(t *testing.T) {
+		// Narrowing is accepted when the value fits.
+		data, err := memorypack.SerializeWithOptions(&V3{Count: 300, Delta: -1, Flags: 255}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var fits V1
+		if err = memorypack.DeserializeWithOptions(data, &fits, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if fits != (V1{Count: 300, Delta: -1, Flags: 255}) {
+			t.Errorf("Got %+v", fits)
+		}
+
+		for _, tc := range []struct {
+			name  string
+			value V3
+		}{
+			{"Overflow", V3{Count: 40_000}},
+			{"NegativeToUnsigned", V3{Flags: -1}},
+		} {
+			data, err := memorypack.SerializeWithOptions(&tc.value, opts)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			var result V1
+			err = memorypack.DeserializeWithOptions(data, &result, opts)
+			if err == nil || !strings.Contains(err.Error(), "overflows") {
+				t.Errorf("%s: expected overflow error, got %v", tc.name, err)
+			}
+		}
+	})
+
+	t.Run("FloatsStayStrict", func(t *testing.T) {
+		data, err := memorypack.SerializeWithOptions(float32(1.5), opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result int32
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err == nil {
+			t.Error("Expected primitive mismatch for a float into an int")
+		}
+	})
+}
+
+// TestReinterpretSignedness tests decoding integers written with the other
+// signedness.
+func TestReinterpretSignedness(t *testing.T) {
+	type V1 struct {
+		ID    uint32
+		Small uint8
+	}
+	type V2 struct {
+		ID    int32
+		Small int8
+	}
+
+	strict := memorypack.Options{TaggedPrimitives: true}
+	lenient := memorypack.Options{TaggedPrimitives: true, ReinterpretSignedness: true}
+	data, err := memorypack.SerializeWithOptions(&V1{ID: 0x80000001, Small: 0xFF}, strict)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("Strict", func(t *testing.T) {
+		var result V2
+		err := memorypack.DeserializeWithOptions(data, &result, strict)
+		if err == nil || !strings.Contains(err.Error(), "primitive mismatch") {
+			t.Errorf("Expected a primitive mismatch, got %v", err)
+		}
+	})
+
+	t.Run("Lenient", func(t *testing.T) {
+		var result V2
+		if err := memorypack.DeserializeWithOptions(data, &result, lenient); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if want := (V2{ID: math.MinInt32 + 1, Small: -1}); result != want {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+
+		// Reinterpreting back restores the original bits.
+		back, err := memorypack.SerializeWithOptions(&result, lenient)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var v1 V1
+		if err = memorypack.DeserializeWithOptions(back, &v1, lenient); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if v1 != (V1{ID: 0x80000001, Small: 0xFF}) {
+			t.Errorf("Got %+v", v1)
+		}
+	})
+
+	t.Run("DifferentWidth", func(t *testing.T) {
+		var result struct {
+			ID    int64
+			Small int8
+		}
+		if err := memorypack.DeserializeWithOptions(data, &result, lenient); err == nil {
+			t.Error("Expected error for an integer of a different width")
+		}
+	})
+}
+
+// TestPackBools tests bitset encoding of bool slices.
+func TestPackBools(t *testing.T) {
+	opts := memorypack.Options{PackBools: true}
+
+	flags := make([]bool, 1000)
+	for i := range flags {
+		flags[i] = i%3 == 0 || i%7 == 0
+	}
+
+	t.Run("Large", func(t *testing.T) {
+		data := testRoundTripWithOptions(t, flags, opts)
+		if want := 4 + 125; len(data) != want {
+			t.Errorf("Expected %d bytes, got %d", want, len(data))
+		}
+	})
+
+	t.Run("EdgeLengths", func(t *testing.T) {
+		testRoundTripWithOptions(t, []bool(nil), opts)
+		testRoundTripWithOptions(t, []bool{}, opts)
+		testRoundTripWithOptions(t, []bool{true}, opts)
+		testRoundTripWithOptions(t, []bool{true, false, true, true, false, false, true, true, true}, opts)
+	})
+
+	t.Run("StructField", func(t *testing.T) {
+		type Mask struct {
+			Name string
+			Bits []bool
+		}
+		testRoundTripWithOptions(t, Mask{Name: "m", Bits: flags[:13]}, opts)
+	})
+
+	t.Run("CrossOptions", func(t *testing.T) {
+		type Mask struct {
+			Name string
+			Bits []bool
+		}
+		original := Mask{Name: "m", Bits: flags[:21]}
+
+		// Packed data decodes without the option, and plain data with it.
+		for _, tc := range []struct {
+			name        string
+			write, read memorypack.Options
+		}{
+			{"PackedToPlain", opts, memorypack.Options{}},
+			{"PlainToPacked", memorypack.Options{}, opts},
+		} {
+			data, err := memorypack.SerializeWithOptions(&original, tc.write)
+			if err != nil {
+				t.Fatalf("%s: Serialize failed: %v", tc.name, err)
+			}
+			var result Mask
+			if err = memorypack.DeserializeWithOptions(data, &result, tc.read); err != nil {
+				t.Fatalf("%s: Deserialize failed: %v", tc.name, err)
+			}
+			if !reflect.DeepEqual(result, original) {
+				t.Errorf("%s: got %+v, want %+v", tc.name, result, original)
+			}
+		}
+	})
+}
+
+// TestRejectDuplicateKeys tests detection of repeated keys in a map stream.
+func TestRejectDuplicateKeys(t *testing.T) {
+	writer := memorypack.NewWriter(0)
+	writer.WriteCollectionHeader(3)
+	for _, entry := range []struct {
+		key   int32
+		value string
+	}{{1, "a"}, {2, "b"}, {1, "c"}} {
+		writer.WriteInt32(entry.key)
+		writer.WriteString(entry.value)
+	}
+	data := writer.GetBytes()
+
+	var lenient map[int32]string
+	if err := memorypack.Deserialize(data, &lenient); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if len(lenient) != 2 || lenient[1] != "c" {
+		t.Errorf("Expected the last value to win by default, got %v", lenient)
+	}
+
+	var strict map[int32]string
+	err := memorypack.DeserializeWithOptions(data, &strict, memorypack.Options{RejectDuplicateKeys: true})
+	if err == nil || err.Error() != "duplicate map key 1" {
+		t.Errorf("Expected duplicate key error, got %v", err)
+	}
+
+	testRoundTripWithOptions(t, map[string]int{"a": 1, "b": 2}, memorypack.Options{RejectDuplicateKeys: true})
+}
+
+// TestFieldFilter tests excluding struct fields at runtime.
+func TestFieldFilter(t *testing.T) {
+	type Credentials struct {
+		User     string
+		Password string
+		Port     int32
+	}
+	type Public struct {
+		User string
+		Port int32
+	}
+
+	opts := memorypack.Options{
+		FieldFilter: func(structType reflect.Type, fieldName string) bool {
+			return structType != reflect.TypeFor[Credentials]() || fieldName != "Password"
+		},
+	}
+	original := []Credentials{{User: "admin", Password: "hunter2", Port: 22}}
+
+	data, err := memorypack.SerializeWithOptions(&original, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if bytes.Contains(data, []byte("hunter2")) {
+		t.Error("Filtered field found in output")
+	}
+
+	want, err := memorypack.Serialize(&[]Public{{User: "admin", Port: 22}})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Got % x, want % x", data, want)
+	}
+
+	var result []Credentials
+	if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if len(result) != 1 || result[0] != (Credentials{User: "admin", Port: 22}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	// The filter does not leak into serialization without it.
+	unfiltered, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if !bytes.Contains(unfiltered, []byte("hunter2")) {
+		t.Error("Field missing from unfiltered output")
+	}
+}
+
+// TestTagName tests that option sets describing the same type differently do
+// not share cached field data.
+func TestTagName(t *testing.T) {
+	type Dual struct {
+		A int32  `memorypack:"0" wire:"-"`
+		B string `memorypack:"1" wire:"0"`
+		C bool   `memorypack:"-" wire:"1"`
+	}
+	original := Dual{A: 7, B: "b", C: true}
+
+	for _, tc := range []struct {
+		name string
+		opts memorypack.Options
+		want Dual
+	}{
+		{"Default", memorypack.Options{}, Dual{A: 7, B: "b"}},
+		{"Wire", memorypack.Options{TagName: "wire"}, Dual{B: "b", C: true}},
+		{"WireFiltered", memorypack.Options{
+			TagName:     "wire",
+			FieldFilter: func(_ reflect.Type, name string) bool { return name != "B" },
+		}, Dual{C: true}},
+		{"DefaultAgain", memorypack.Options{TagName: "memorypack"}, Dual{A: 7, B: "b"}},
+	} {
+		data, err := memorypack.SerializeWithOptions(&original, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: Serialize failed: %v", tc.name, err)
+		}
+		var result Dual
+		if err = memorypack.DeserializeWithOptions(data, &result, tc.opts); err != nil {
+			t.Fatalf("%s: Deserialize failed: %v", tc.name, err)
+		}
+		if result != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, result, tc.want)
+		}
+	}
+}
+
+// TestFieldOrder tests each strategy for ordering struct fields.
+func TestFieldOrder(t *testing.T) {
+	type Legacy struct {
+		Zeta  int32 `memorypack:"1"`
+		Alpha int32 `memorypack:"2"`
+		Mid   int32 `memorypack:"0"`
+	}
+	original := Legacy{Zeta: 1, Alpha: 2, Mid: 3}
+
+	for _, tc := range []struct {
+		name  string
+		order memorypack.FieldOrder
+		want  []int32
+	}{
+		{"Tag", memorypack.FieldOrderTag, []int32{3, 1, 2}},
+		{"Declaration", memorypack.FieldOrderDeclaration, []int32{1, 2, 3}},
+		{"Reverse", memorypack.FieldOrderReverse, []int32{3, 2, 1}},
+		{"Alphabetical", memorypack.FieldOrderAlphabetical, []int32{2, 3, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := memorypack.Options{FieldOrder: tc.order}
+			data := testRoundTripWithOptions(t, original, opts)
+
+			reader := memorypack.NewReader(data)
+			if count, _, err := reader.ReadObjectHeader(); err != nil || count != 3 {
+				t.Fatalf("Expected 3 fields, got %d, err: %v", count, err)
+			}
+			got := make([]int32, 3)
+			for i := range got {
+				var err error
+				if got[i], err = reader.ReadInt32(); err != nil {
+					t.Fatalf("ReadInt32 failed: %v", err)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Got field sequence %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRejectNonFinite tests rejecting NaN and infinite floats with their path.
+func TestRejectNonFinite(t *testing.T) {
+	opts := memorypack.Options{RejectNonFinite: true}
+
+	type Reading struct {
+		Sensor string
+		Value  float64
+	}
+	type Batch struct {
+		Readings []Reading
+		Limits   map[string]float32
+	}
+
+	testRoundTripWithOptions(t, Batch{Readings: []Reading{{"a", 1.5}}, Limits: map[string]float32{"max": 3}}, opts)
+
+	for _, tc := range []struct {
+		value any
+		want  string
+	}{
+		{&Batch{Readings: []Reading{{"a", 1}, {"b", math.NaN()}}}, "Readings[1].Value: float value NaN is not finite"},
+		{&Batch{Limits: map[string]float32{"max": float32(math.Inf(1))}}, `Limits["max"]: float value +Inf is not finite`},
+		{[]float64{0, math.Inf(-1)}, "[1]: float value -Inf is not finite"},
+	} {
+		_, err := memorypack.SerializeWithOptions(tc.value, opts)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("Got error %v, want %q", err, tc.want)
+		}
+
+		// The default keeps accepting non-finite values.
+		if _, err = memorypack.Serialize(tc.value); err != nil {
+			t.Errorf("Serialize without the option failed: %v", err)
+		}
+	}
+}
+
+// TestDeltaInts tests delta encoding of integer slices.
+func TestDeltaInts(t *testing.T) {
+	opts := memorypack.Options{DeltaInts: true}
+
+	sorted := make([]int64, 100_000)
+	for i := range sorted {
+		sorted[i] = int64(i*3 + i%7)
+	}
+	data := testRoundTripWithOptions(t, sorted, opts)
+	plain, err := memorypack.Serialize(&sorted)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(data) >= len(plain)/4 {
+		t.Errorf("Expected delta encoding to be much smaller: %d vs %d bytes", len(data), len(plain))
+	}
+
+	testRoundTripWithOptions(t, []int{5, -3, 100, 0, math.MaxInt, math.MinInt, 7}, opts)
+	testRoundTripWithOptions(t, []int64{}, opts)
+	testRoundTripWithOptions(t, struct {
+		IDs  []int64
+		None []int
+	}{IDs: []int64{10, 11, 12}}, opts)
+}
+
+// TestInvokeFuncs tests encoding zero-argument funcs by their result.
+func TestInvokeFuncs(t *testing.T) {
+	type Config struct {
+		Name  string
+		Limit func() int
+		Unset func() string
+	}
+	opts := memorypack.Options{InvokeFuncs: true}
+
+	calls := 0
+	original := Config{Name: "c", Limit: func() int { calls++; return 42 }}
+	data, err := memorypack.SerializeWithOptions(&original, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected Limit to be called once, got %d", calls)
+	}
+
+	var result Config
+	if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if result.Name != "c" || result.Limit == nil || result.Limit() != 42 {
+		t.Errorf("Got %+v", result)
+	}
+	if result.Unset != nil {
+		t.Error("Expected nil func to stay nil")
+	}
+
+	if _, err = memorypack.Serialize(&original); err == nil {
+		t.Error("Expected error for func fields without the option")
+	}
+
+	t.Run("NullMarkerResults", func(t *testing.T) {
+		// Results whose encoding starts with 0xFF must not read as a nil func.
+		long := strings.Repeat("x", 256)
+		original := Config{Name: "n", Limit: func() int { return -1 }, Unset: func() string { return long }}
+		data, err := memorypack.SerializeWithOptions(&original, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result Config
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result.Limit == nil || result.Limit() != -1 {
+			t.Error("Expected Limit to return -1")
+		}
+		if result.Unset == nil || result.Unset() != long {
+			t.Error("Expected Unset to return the 256-byte string")
+		}
+	})
+}
+
+// TestCanonicalMaps tests that map entries are written in key order.
+func TestCanonicalMaps(t *testing.T) {
+	opts := memorypack.Options{CanonicalMaps: true}
+
+	// readKeys reads the keys of an encoded map using readEntry.
+	readKeys := func(t *testing.T, data []byte, readEntry func(r *memorypack.Reader) (int64, error)) []int64 {
+		t.Helper()
+		reader := memorypack.NewReader(data)
+		n, _, err := reader.ReadCollectionHeader()
+		if err != nil {
+			t.Fatalf("ReadCollectionHeader failed: %v", err)
+		}
+		keys := make([]int64, n)
+		for i := range keys {
+			if keys[i], err = readEntry(reader); err != nil {
+				t.Fatalf("Reading entry %d failed: %v", i, err)
+			}
+		}
+		return keys
+	}
+
+	// serializeStable serializes value several times and checks that every
+	// encoding is identical.
+	serializeStable := func(t *testing.T, value any) []byte {
+		t.Helper()
+		first, err := memorypack.SerializeWithOptions(value, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		for range 20 {
+			data, err := memorypack.SerializeWithOptions(value, opts)
+			if err != nil {
+				t.Fatalf("Serialize failed: %v", err)
+			}
+			if !bytes.Equal(data, first) {
+				t.Fatal("Encodings of the same map differ")
+			}
+		}
+		return first
+	}
+
+	t.Run("Int", func(t *testing.T) {
+		original := map[int]int{}
+		for _, k := range []int{100, -5, 9, 10, 2, -100, 0} {
+			original[k] = k * 2
+		}
+		data := serializeStable(t, &original)
+		keys := readKeys(t, data, func(r *memorypack.Reader) (int64, error) {
+			key, err := r.ReadInt64()
+			if err == nil {
+				_, err = r.ReadInt64()
+			}
+			return key, err
+		})
+		if want := []int64{-100, -5, 0, 2, 9, 10, 100}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("Got keys %v, want %v", keys, want)
+		}
+		testRoundTripWithOptions(t, original, opts)
+	})
+
+	t.Run("Uint64", func(t *testing.T) {
+		original := map[uint64]string{math.MaxUint64: "max", 2: "two", 10: "ten", 1 << 40: "big"}
+		data := serializeStable(t, &original)
+		keys := readKeys(t, data, func(r *memorypack.Reader) (int64, error) {
+			key, err := r.ReadInt64()
+			if err == nil {
+				_, err = r.ReadString()
+			}
+			return key, err
+		})
+		if want := []int64{2, 10, 1 << 40, -1}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("Got keys %v, want %v", keys, want)
+		}
+		testRoundTripWithOptions(t, original, opts)
+	})
+
+	t.Run("Int8", func(t *testing.T) {
+		original := map[int8]bool{3: true, -128: false, 127: true, -1: true, 0: false}
+		data := serializeStable(t, &original)
+		keys := readKeys(t, data, func(r *memorypack.Reader) (int64, error) {
+			key, err := r.ReadByte()
+			if err == nil {
+				_, err = r.ReadBool()
+			}
+			return int64(int8(key)), err
+		})
+		if want := []int64{-128, -1, 0, 3, 127}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("Got keys %v, want %v", keys, want)
+		}
+		testRoundTripWithOptions(t, original, opts)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		original := map[string]int32{"b": 1, "a": 2, "ab": 3, "B": 4}
+		data := serializeStable(t, &original)
+		var got []string
+		reader := memorypack.NewReader(data)
+		n, _, _ := reader.ReadCollectionHeader()
+		for range n {
+			key, _ := reader.ReadString()
+			_, _ = reader.ReadInt32()
+			got = append(got, key)
+		}
+		if want := []string{"B", "a", "ab", "b"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Got keys %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		type Index struct {
+			Shards []map[string]int
+			Groups map[string][]map[int64]string
+		}
+		original := Index{
+			Shards: []map[string]int{{"z": 1, "a": 2, "m": 3}, {"y": 4, "b": 5}, nil},
+			Groups: map[string][]map[int64]string{
+				"second": {{3: "c", 1: "a", 2: "b"}},
+				"first":  {{-1: "n"}, {}},
+			},
+		}
+		serializeStable(t, &original)
+		data := testRoundTripWithOptions(t, original, opts)
+
+		// Decoding into a reused value replaces its inner containers.
+		reused := Index{
+			Shards: []map[string]int{{"stale": 9}, {"stale": 9}, {"stale": 9}, {"stale": 9}},
+			Groups: map[string][]map[int64]string{"stale": {{9: "x"}}, "first": {{7: "old"}}},
+		}
+		if err := memorypack.DeserializeWithOptions(data, &reused, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if !reflect.DeepEqual(reused, original) {
+			t.Errorf("Got %+v, want %+v", reused, original)
+		}
+	})
+
+	t.Run("UnorderedKeys", func(t *testing.T) {
+		original := map[[2]int]int{{1, 2}: 3}
+		if _, err := memorypack.SerializeWithOptions(&original, opts); err == nil {
+			t.Error("Expected error for keys without a canonical order")
+		}
+	})
+}
+
+// TestMaxStringLength tests rejecting long strings before decoding them.
+func TestMaxStringLength(t *testing.T) {
+	opts := memorypack.Options{MaxStringLength: 16}
+
+	testRoundTripWithOptions(t, "sixteen bytes ok", opts)
+
+	// The header claims 1 MiB and the buffer holds it, so only the limit
+	// stops the string from being decoded.
+	const claimed = 1 << 20
+	data := make([]byte, 8+claimed)
+	binary.LittleEndian.PutUint32(data, ^uint32(claimed))
+	binary.LittleEndian.PutUint32(data[4:], claimed)
+
+	var result string
+	err := memorypack.DeserializeWithOptions(data, &result, opts)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+		t.Errorf("Expected the string to exceed the limit, got %v", err)
+	}
+	if err = memorypack.Deserialize(data, &result); err != nil || len(result) != claimed {
+		t.Errorf("Expected the default to accept the string, got %d bytes, err: %v", len(result), err)
+	}
+}
+
+type shortStrings struct {
+	A, B, C, D, E, F, G, H, I, J string
+}
+
+// TestCompactStrings tests one-byte headers for short strings.
+func TestCompactStrings(t *testing.T) {
+	opts := memorypack.Options{CompactStrings: true}
+
+	long := strings.Repeat("x", 200)
+	testRoundTripWithOptions(t, shortStrings{A: "a", B: "", C: "日本語", D: strings.Repeat("y", 127), E: long}, opts)
+	testRoundTripWithOptions(t, map[string][]string{"k": {"v", long}}, opts)
+
+	data := testRoundTripWithOptions(t, "abc", opts)
+	if want := []byte{3, 'a', 'b', 'c'}; !bytes.Equal(data, want) {
+		t.Errorf("Expected % x, got % x", want, data)
+	}
+	data = testRoundTripWithOptions(t, strings.Repeat("z", 128), opts)
+	if want := 1 + 8 + 128; len(data) != want {
+		t.Errorf("Expected %d bytes for a long string, got %d", want, len(data))
+	}
+}
+
+// TestMaxAllocBytes tests the allocation budget across a whole decode.
+func TestMaxAllocBytes(t *testing.T) {
+	// Each empty inner slice is 4 bytes on the wire but a 24-byte slice
+	// header once decoded.
+	original := make([][]int32, 10_000)
+	for i := range original {
+		original[i] = []int32{}
+	}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var result [][]int32
+	err = memorypack.DeserializeWithOptions(data, &result, memorypack.Options{MaxAllocBytes: 100_000})
+	if err == nil || !strings.Contains(err.Error(), "allocation budget exceeded") {
+		t.Errorf("Expected allocation budget error, got %v", err)
+	}
+	if err = memorypack.DeserializeWithOptions(data, &result, memorypack.Options{MaxAllocBytes: 1 << 20}); err != nil {
+		t.Errorf("Deserialize within budget failed: %v", err)
+	}
+
+	// The budget is shared by every value in the decode.
+	type Names struct {
+		First, Second, Third string
+	}
+	names := Names{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 40)}
+	data, err = memorypack.Serialize(&names)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var decoded Names
+	err = memorypack.DeserializeWithOptions(data, &decoded, memorypack.Options{MaxAllocBytes: 100})
+	if err == nil || !strings.Contains(err.Error(), "allocation budget exceeded") {
+		t.Errorf("Expected allocation budget error, got %v", err)
+	}
+	testRoundTripWithOptions(t, names, memorypack.Options{MaxAllocBytes: 120})
+
+	t.Run("Gzip", func(t *testing.T) {
+		// A megabyte of zeros compresses to about a kilobyte on the wire.
+		type Blob struct {
+			Body []byte `memorypack:"0,gzip"`
+		}
+		data, err := memorypack.Serialize(&Blob{Body: make([]byte, 1<<20)})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if len(data) > 4096 {
+			t.Fatalf("Expected a small payload, got %d bytes", len(data))
+		}
+		var result Blob
+		err = memorypack.DeserializeWithOptions(data, &result, memorypack.Options{MaxAllocBytes: 64 << 10})
+		if err == nil || !strings.Contains(err.Error(), "allocation budget exceeded") {
+			t.Errorf("Expected allocation budget error, got %v", err)
+		}
+		if err = memorypack.DeserializeWithOptions(data, &result, memorypack.Options{MaxAllocBytes: 4 << 20}); err != nil || len(result.Body) != 1<<20 {
+			t.Errorf("Deserialize within budget failed: %v", err)
+		}
+	})
+}
+
+// BenchmarkPackedBools compares plain and bit-packed encoding of a 1000-element []bool.
+func BenchmarkPackedBools(b *testing.B) {
+	flags := make([]bool, 1000)
+	for i := range flags {
+		flags[i] = i%2 == 0
+	}
+
+	for _, bc := range []struct {
+		name string
+		opts memorypack.Options
+	}{
+		{"Plain", memorypack.Options{}},
+		{"Packed", memorypack.Options{PackBools: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			for range b.N {
+				data, err := memorypack.SerializeWithOptions(&flags, bc.opts)
+				if err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+
+				var result []bool
+				if err = memorypack.DeserializeWithOptions(data, &result, bc.opts); err != nil {
+					b.Fatalf("Deserialize failed: %v", err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "encoded-bytes")
+		})
+	}
+}
+
+// BenchmarkDeltaInts compares plain and delta encoding of a sorted 100k-element []int64.
+func BenchmarkDeltaInts(b *testing.B) {
+	ids := make([]int64, 100_000)
+	for i := range ids {
+		ids[i] = int64(i * 3)
+	}
+
+	for _, bc := range []struct {
+		name string
+		opts memorypack.Options
+	}{
+		{"Plain", memorypack.Options{}},
+		{"Delta", memorypack.Options{DeltaInts: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			for range b.N {
+				data, err := memorypack.SerializeWithOptions(&ids, bc.opts)
+				if err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+
+				var result []int64
+				if err = memorypack.DeserializeWithOptions(data, &result, bc.opts); err != nil {
+					b.Fatalf("Deserialize failed: %v", err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "encoded-bytes")
+		})
+	}
+}
+
+// BenchmarkCompactStrings compares standard and compact headers on a struct
+// with ten short strings.
+func BenchmarkCompactStrings(b *testing.B) {
+	value := shortStrings{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta", "iota", "kappa"}
+
+	for _, bc := range []struct {
+		name string
+		opts memorypack.Options
+	}{
+		{"Standard", memorypack.Options{}},
+		{"Compact", memorypack.Options{CompactStrings: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var size int
+			for range b.N {
+				data, err := memorypack.SerializeWithOptions(&value, bc.opts)
+				if err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+
+				var result shortStrings
+				if err = memorypack.DeserializeWithOptions(data, &result, bc.opts); err != nil {
+					b.Fatalf("Deserialize failed: %v", err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "encoded-bytes")
+		})
+	}
+}
+
+// TestTupleMode tests writing structs without object headers.
+func TestTupleMode(t *testing.T) {
+	type Vec struct {
+		X, Y int32
+	}
+	type Segment struct {
+		From, To Vec
+	}
+	type Path struct {
+		Name     string
+		Segments []Segment
+		Origin   *Vec
+		Label    *Segment
+	}
+	opts := memorypack.Options{TupleMode: true}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		testRoundTripWithOptions(t, Vec{X: -1, Y: 2}, opts)
+		testRoundTripWithOptions(t, Path{Name: "empty"}, opts)
+		// Origin starts with 0xFF bytes, which must not read as a nil pointer.
+		testRoundTripWithOptions(t, Path{
+			Name:     "p",
+			Segments: []Segment{{From: Vec{1, 2}, To: Vec{3, 4}}, {}},
+			Origin:   &Vec{X: -1, Y: -1},
+			Label:    &Segment{},
+		}, opts)
+		testRoundTripWithOptions(t, map[string]Segment{"s": {To: Vec{5, 6}}}, opts)
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		value := Path{Segments: make([]Segment, 10), Origin: &Vec{}}
+		standard, err := memorypack.Serialize(&value)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		tuple := testRoundTripWithOptions(t, value, opts)
+
+		// Path, 10 segments of 3 structs each, and Origin lose their headers;
+		// Origin gains a presence marker.
+		if want := len(standard) - (1 + 10*3 + 1) + 1; len(tuple) != want {
+			t.Errorf("Expected %d tuple bytes (standard %d), got %d", want, len(standard), len(tuple))
+		}
+	})
+
+	t.Run("PODStruct", func(t *testing.T) {
+		data := testRoundTripWithOptions(t, Vec{X: 1, Y: 2}, opts)
+		if want := []byte{1, 0, 0, 0, 2, 0, 0, 0}; !bytes.Equal(data, want) {
+			t.Errorf("Expected %x, got %x", want, data)
+		}
+	})
+
+	t.Run("HugeLength", func(t *testing.T) {
+		// Each Segment takes 16 bytes, so 31 bytes cannot hold two of them.
+		for _, data := range [][]byte{
+			{0xFF, 0xFF, 0xFF, 0x7F},
+			append([]byte{2, 0, 0, 0}, make([]byte, 31)...),
+		} {
+			var result []Segment
+			err := memorypack.DeserializeWithOptions(data, &result, opts)
+			if !errors.Is(err, memorypack.ErrTruncated) || !strings.Contains(err.Error(), "at least 16 bytes") {
+				t.Errorf("Expected the length to be rejected, got %v", err)
+			}
+		}
+	})
+}
+
+// TestFieldPresence tests omitting struct fields that hold their zero value.
+func TestFieldPresence(t *testing.T) {
+	type Settings struct {
+		Name    string
+		Retries int32
+		Verbose bool
+		Tags    []string
+		Limit   *int64
+	}
+	opts := memorypack.Options{FieldPresence: true}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		limit := int64(0)
+		testRoundTripWithOptions(t, Settings{}, opts)
+		testRoundTripWithOptions(t, Settings{Name: "s", Retries: 3, Verbose: true, Tags: []string{"a"}, Limit: &limit}, opts)
+		testRoundTripWithOptions(t, []Settings{{Retries: -1}, {Tags: []string{}}}, opts)
+	})
+
+	t.Run("AbsentKeepsTarget", func(t *testing.T) {
+		data, err := memorypack.SerializeWithOptions(&Settings{Name: "s"}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		// Header, bitmap, Name, Retries and Verbose; Tags and Limit are nil.
+		if want := 1 + 1 + 8 + 1 + 4 + 1; len(data) != want {
+			t.Errorf("Expected %d bytes, got %d: %x", want, len(data), data)
+		}
+
+		defaultLimit := int64(10)
+		result := Settings{Name: "default", Retries: 5, Verbose: true, Tags: []string{"d"}, Limit: &defaultLimit}
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		// Explicit zero values overwrite the target; absent fields keep it.
+		if want := (Settings{Name: "s", Tags: []string{"d"}, Limit: &defaultLimit}); !reflect.DeepEqual(result, want) {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("ZeroIsPresent", func(t *testing.T) {
+		zero := int64(0)
+		data, err := memorypack.SerializeWithOptions(&Settings{Tags: []string{}, Limit: &zero}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		defaultLimit := int64(10)
+		result := Settings{Tags: []string{"d"}, Limit: &defaultLimit}
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result.Limit == nil || *result.Limit != 0 || result.Tags == nil || len(result.Tags) != 0 {
+			t.Errorf("Expected the zero Limit and empty Tags to be decoded, got %+v", result)
+		}
+	})
+
+	t.Run("InvalidBitmap", func(t *testing.T) {
+		data := []byte{5, 0xFF}
+		var result Settings
+		if err := memorypack.DeserializeWithOptions(data, &result, opts); err == nil {
+			t.Error("Expected error for bits beyond the field count")
+		}
+	})
+}
+
+// TestZeroStructs tests writing all-zero structs as a single marker byte.
+func TestZeroStructs(t *testing.T) {
+	type Large struct {
+		A, B, C, D int64
+		Name, Tag  string
+		Ratio      float64
+		Items      []int32
+		Inner      struct{ X, Y int32 }
+	}
+	type Node struct {
+		Value int32
+		Child Large
+		Next  *Large
+	}
+	opts := memorypack.Options{ZeroStructs: true}
+
+	t.Run("Large", func(t *testing.T) {
+		data := testRoundTripWithOptions(t, Large{}, opts)
+		if len(data) != 1 {
+			t.Errorf("Expected one byte, got %d: %x", len(data), data)
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		testRoundTripWithOptions(t, Large{A: 1, Inner: struct{ X, Y int32 }{Y: 2}}, opts)
+		testRoundTripWithOptions(t, Node{Value: 1, Next: &Large{}}, opts)
+		testRoundTripWithOptions(t, []Node{{}, {Child: Large{Name: "x"}}, {}}, opts)
+		testRoundTripWithOptions(t, map[string]Large{"zero": {}, "one": {B: 1}}, opts)
+
+		// Header, Value, the Child marker and the Next marker.
+		data := testRoundTripWithOptions(t, Node{Value: 1, Next: &Large{}}, opts)
+		if want := 1 + 4 + 1 + 1; len(data) != want {
+			t.Errorf("Expected %d bytes, got %d: %x", want, len(data), data)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		data, err := memorypack.SerializeWithOptions(&Large{}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		result := Large{A: 5, Items: []int32{1}}
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if !reflect.DeepEqual(result, Large{}) {
+			t.Errorf("Expected the zero value, got %+v", result)
+		}
+	})
+}
+
+// TestDenseIntMaps tests that maps with dense integer keys are written as a
+// bitmap and values.
+func TestDenseIntMaps(t *testing.T) {
+	opts := memorypack.Options{DenseIntMaps: true}
+
+	t.Run("Dense", func(t *testing.T) {
+		original := map[int]string{}
+		for i := range 100 {
+			if i%10 != 3 {
+				original[i] = strconv.Itoa(i)
+			}
+		}
+		dense := testRoundTripWithOptions(t, original, opts)
+		pairs := testRoundTripWithOptions(t, original, memorypack.Options{})
+		// The keys are replaced by the layout byte, the span and a 13-byte bitmap.
+		if want := len(pairs) - 8*len(original) + 1 + 4 + 13; len(dense) != want {
+			t.Errorf("Expected %d bytes, got %d", want, len(dense))
+		}
+	})
+
+	t.Run("Sparse", func(t *testing.T) {
+		original := map[int32]float64{1: 1.5, 1 << 20: 2, -4: 3}
+		data := testRoundTripWithOptions(t, original, opts)
+		// Header, the layout byte and three pairs.
+		if want := 4 + 1 + 3*12; len(data) != want {
+			t.Errorf("Expected %d bytes, got %d", want, len(data))
+		}
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		testRoundTripWithOptions(t, map[uint8]bool{0: true, 1: false, 2: true, 3: true, 4: true, 5: true, 7: true}, opts)
+		testRoundTripWithOptions(t, map[uint64][]int32{0: {1}, 1: nil, 2: {}, 3: {4}}, opts)
+		testRoundTripWithOptions(t, map[int16]int16{}, opts)
+		testRoundTripWithOptions(t, struct {
+			M map[int]int
+			N map[string]int
+		}{M: map[int]int{0: 1, 1: 2, 2: 3}, N: map[string]int{"a": 1}}, opts)
+		var nilMap map[int]int
+		testRoundTripWithOptions(t, nilMap, opts)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		data, err := memorypack.SerializeWithOptions(&map[int]int64{0: 1, 1: 2, 2: 3}, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result map[int]int64
+		if err = memorypack.DeserializeWithOptions(data[:len(data)-1], &result, opts); err == nil {
+			t.Error("Expected error for truncated data")
+		}
+		// Mark a fourth key in the bitmap.
+		data[4+1+4] |= 1 << 3
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err == nil {
+			t.Error("Expected error for a bitmap that disagrees with the header")
+		}
+	})
+}
+
+// TestArrayLength tests decoding arrays from streams of a different length.
+func TestArrayLength(t *testing.T) {
+	type Pair struct {
+		A, B string
+	}
+	shorter, err := memorypack.Serialize(&[2]Pair{{"a", "b"}, {"c", "d"}})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	longer, err := memorypack.Serialize(&[]Pair{{"a", "b"}, {"c", "d"}, {"e", "f"}, {"g", "h"}})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("ZeroFill", func(t *testing.T) {
+		result := [3]Pair{{"x", "x"}, {"x", "x"}, {"x", "x"}}
+		if err := memorypack.Deserialize(shorter, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if want := [3]Pair{{"a", "b"}, {"c", "d"}, {}}; result != want {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		type Wrapper struct {
+			Pairs [3]Pair
+			After int32
+		}
+		data, err := memorypack.Serialize(&struct {
+			Pairs []Pair
+			After int32
+		}{Pairs: []Pair{{"a", "b"}, {"c", "d"}, {"e", "f"}, {"g", "h"}}, After: 7})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result Wrapper
+		if err = memorypack.Deserialize(data, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if want := (Wrapper{Pairs: [3]Pair{{"a", "b"}, {"c", "d"}, {"e", "f"}}, After: 7}); result != want {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		opts := memorypack.Options{StrictArrayLength: true}
+		for _, data := range [][]byte{shorter, longer} {
+			var result [3]Pair
+			if err := memorypack.DeserializeWithOptions(data, &result, opts); err == nil {
+				t.Error("Expected error for a mismatched array length")
+			}
+		}
+		testRoundTripWithOptions(t, [3]Pair{{"a", "b"}}, opts)
+	})
+}
@@ -0,0 +1,55 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldPathError records the location of the value that caused a
+// serialization error, such as "Orders[2].Price".
+type fieldPathError struct {
+	path string
+	err  error
+}
+
+func (e *fieldPathError) Error() string {
+	return e.path + ": " + e.err.Error()
+}
+
+func (e *fieldPathError) Unwrap() error {
+	return e.err
+}
+
+// withField prefixes the path of err with a struct field name.
+func withField(err error, name string) error {
+	return withPath(err, name)
+}
+
+// withIndex prefixes the path of err with a slice or array index.
+func withIndex(err error, i int) error {
+	return withPath(err, "["+strconv.Itoa(i)+"]")
+}
+
+// withKey prefixes the path of err with a map key.
+func withKey(err error, key reflect.Value) error {
+	if key.Kind() == reflect.String {
+		return withPath(err, fmt.Sprintf("[%q]", key.String()))
+	}
+	return withPath(err, fmt.Sprintf("[%v]", key))
+}
+
+// withPath prefixes the path of err with segment, wrapping err on first use.
+func withPath(err error, segment string) error {
+	pe, ok := err.(*fieldPathError)
+	switch {
+	case !ok:
+		return &fieldPathError{path: segment, err: err}
+	case strings.HasPrefix(pe.path, "["):
+		pe.path = segment + pe.path
+	default:
+		pe.path = segment + "." + pe.path
+	}
+	return pe
+}
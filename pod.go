@@ -0,0 +1,93 @@
+package memorypack
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// podFastPath enables bulk copying of plain-old-data structs. It is only
+// possible on little-endian machines, where memory matches the wire layout.
+var podFastPath = isLittleEndian()
+
+func isLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+// podLayout returns the encoded size of the fields of t when their wire layout
+// is identical to the memory layout of t, or 0 otherwise. It also returns the
+// offsets of bool fields, which must be normalized when decoding.
+func podLayout(t reflect.Type, fields []fieldInfo) (int, []int) {
+	if len(fields) == 0 || len(fields) != t.NumField() {
+		return 0, nil
+	}
+
+	var bools []int
+	offset := 0
+	for _, field := range fields {
+		sf := t.Field(field.index)
+		size := fixedWireSize(sf.Type)
+		if size == 0 || field.transformed() || sf.Offset != uintptr(offset) || sf.Type.Size() != uintptr(size) {
+			return 0, nil
+		}
+		if sf.Type.Kind() == reflect.Bool {
+			bools = append(bools, offset)
+		}
+		offset += size
+	}
+	if t.Size() != uintptr(offset) {
+		return 0, nil
+	}
+	return offset, bools
+}
+
+// fixedWireSize returns the encoded size of a primitive type written by
+// writeValue without any header, or 0 for other types.
+func fixedWireSize(t reflect.Type) int {
+	if isKnownType(t) || isFormatterType(t) {
+		return 0
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// canCopyPOD reports whether fd can be encoded or decoded by copying memory
+// with the given options. Options that change how primitives are written or
+// validated need the per-field path.
+func canCopyPOD(fd formatterData, opts *Options) bool {
+	return podFastPath && fd.podSize > 0 && !opts.TaggedPrimitives && !opts.RejectNonFinite && !opts.FieldPresence && !hasFlagMasks.Load() && !hasEnums.Load()
+}
+
+// writePOD writes the fields of an addressable POD struct, after any object
+// header, in one copy.
+func writePOD(writer *Writer, v reflect.Value, fd formatterData) {
+	writer.ensureCapacity(fd.podSize)
+	copy(writer.buffer[writer.pos:], unsafe.Slice((*byte)(v.Addr().UnsafePointer()), fd.podSize))
+	writer.pos += fd.podSize
+}
+
+// readPOD reads the fields of a POD struct, after any object header, in one copy.
+func readPOD(reader *Reader, v reflect.Value, fd formatterData) bool {
+	if !reader.ensure(fd.podSize) {
+		return false
+	}
+	mem := unsafe.Slice((*byte)(v.Addr().UnsafePointer()), fd.podSize)
+	copy(mem, reader.buffer[reader.pos:])
+	for _, offset := range fd.podBools {
+		if mem[offset] != 0 {
+			mem[offset] = 1
+		}
+	}
+	reader.pos += fd.podSize
+	return true
+}
@@ -0,0 +1,118 @@
+package memorypack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type podInts struct {
+	A0, A1, A2, A3, A4, A5, A6, A7, A8, A9           int
+	A10, A11, A12, A13, A14, A15, A16, A17, A18, A19 int
+}
+
+type podMixed struct {
+	X, Y  float64
+	ID    int32
+	Flags uint16
+	Alive bool
+	Level int8
+}
+
+type podPadded struct {
+	Small int8
+	Large int64
+}
+
+// TestPODStructs tests that the bulk-copy path matches the per-field encoding.
+func TestPODStructs(t *testing.T) {
+	values := []any{
+		&podInts{A0: 1, A7: -7, A19: 1 << 40},
+		&podMixed{X: 1.5, Y: -2, ID: 9, Flags: 0xBEEF, Alive: true, Level: -3},
+		&podPadded{Small: 1, Large: 2},
+		&[]podMixed{{ID: 1}, {ID: 2, Alive: true}},
+	}
+
+	for _, value := range values {
+		fast, err := memorypack.Serialize(value)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		prev := memorypack.SetPODFastPath(false)
+		slow, err := memorypack.Serialize(value)
+		memorypack.SetPODFastPath(prev)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if !bytes.Equal(fast, slow) {
+			t.Errorf("%T: fast path wrote % x, per-field path wrote % x", value, fast, slow)
+		}
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		testRoundTrip(t, podInts{A3: 3, A19: -19})
+		testRoundTrip(t, podMixed{X: 1, Alive: true, Level: 127})
+		testRoundTrip(t, map[string]podMixed{"k": {ID: 5}})
+	})
+
+	t.Run("NonCanonicalBool", func(t *testing.T) {
+		data, err := memorypack.Serialize(&podMixed{Alive: true})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		data[1+8+8+4+2] = 2
+
+		var result podMixed
+		if err = memorypack.Deserialize(data, &result); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+		if result != (podMixed{Alive: true}) {
+			t.Errorf("Expected Alive to decode as true, got %+v", result)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		data, err := memorypack.Serialize(&podInts{})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var result podInts
+		if err = memorypack.Deserialize(data[:len(data)-1], &result); err == nil {
+			t.Error("Expected error for truncated data, got nil")
+		}
+	})
+}
+
+// BenchmarkPODStruct compares the bulk-copy path with the per-field reflection
+// loop on a struct of 20 ints.
+func BenchmarkPODStruct(b *testing.B) {
+	value := podInts{A0: 1, A10: 10, A19: 19}
+
+	for _, bc := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"FastPath", true},
+		{"Reflection", false},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			prev := memorypack.SetPODFastPath(bc.enabled)
+			defer memorypack.SetPODFastPath(prev)
+
+			b.ReportAllocs()
+			for range b.N {
+				data, err := memorypack.Serialize(&value)
+				if err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+
+				var result podInts
+				if err = memorypack.Deserialize(data, &result); err != nil {
+					b.Fatalf("Deserialize failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// isFieldPresent reports whether a field is written under FieldPresence. Nil
+// pointers, slices, maps, interfaces and funcs and unset Optionals are
+// absent; every other value, including zero values, is present.
+func isFieldPresent(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func:
+		return !v.IsNil()
+	case reflect.Struct:
+		if isOptionalType(v.Type()) {
+			return v.Field(1).Bool()
+		}
+	}
+	return true
+}
+
+// writePresentFields writes a bitmap with one bit per field, set for fields
+// that are present, followed by those fields only.
+func writePresentFields(writer *Writer, v reflect.Value, fields []fieldInfo) error {
+	size := (len(fields) + 7) / 8
+	writer.ensureCapacity(size)
+	bits := writer.buffer[writer.pos : writer.pos+size]
+	clear(bits)
+	for i, field := range fields {
+		if isFieldPresent(v.Field(field.index)) {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	writer.pos += size
+
+	for i, field := range fields {
+		if bits[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if err := writeField(writer, field, v.Field(field.index)); err != nil {
+			return withField(err, field.name)
+		}
+	}
+	return nil
+}
+
+// readPresentFields reads a presence bitmap and the fields it marks. Fields
+// not marked keep their current value.
+func readPresentFields(reader *Reader, v reflect.Value, fields []fieldInfo) error {
+	size := (len(fields) + 7) / 8
+	if !reader.ensure(size) {
+		return fmt.Errorf("cannot read presence bitmap of %d bytes: %w", size, ErrTruncated)
+	}
+	bits := reader.buffer[reader.pos : reader.pos+size]
+	reader.pos += size
+	if unused := len(fields) % 8; unused != 0 && bits[size-1]>>unused != 0 {
+		return fmt.Errorf("presence bitmap marks fields beyond the %d in the stream", len(fields))
+	}
+
+	for i, field := range fields {
+		if bits[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		fieldValue := v.Field(field.index)
+		if !fieldValue.CanSet() {
+			if err := skipField(reader, field, fieldValue.Type()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := readField(reader, field, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
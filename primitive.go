@@ -0,0 +1,156 @@
+package memorypack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Primitive classes stored in the high nibble of a primitive tag. The low
+// nibble holds the width of the value in bytes.
+const (
+	primitiveClassBool  byte = 1
+	primitiveClassInt   byte = 2
+	primitiveClassUint  byte = 3
+	primitiveClassFloat byte = 4
+)
+
+// primitiveTag returns the tag written before values of the given kind when
+// TaggedPrimitives is set.
+func primitiveTag(kind reflect.Kind) (byte, bool) {
+	switch kind {
+	case reflect.Bool:
+		return primitiveClassBool<<4 | 1, true
+	case reflect.Int8:
+		return primitiveClassInt<<4 | 1, true
+	case reflect.Int16:
+		return primitiveClassInt<<4 | 2, true
+	case reflect.Int32:
+		return primitiveClassInt<<4 | 4, true
+	case reflect.Int, reflect.Int64:
+		return primitiveClassInt<<4 | 8, true
+	case reflect.Uint8:
+		return primitiveClassUint<<4 | 1, true
+	case reflect.Uint16:
+		return primitiveClassUint<<4 | 2, true
+	case reflect.Uint32:
+		return primitiveClassUint<<4 | 4, true
+	case reflect.Uint, reflect.Uint64:
+		return primitiveClassUint<<4 | 8, true
+	case reflect.Float32:
+		return primitiveClassFloat<<4 | 4, true
+	case reflect.Float64:
+		return primitiveClassFloat<<4 | 8, true
+	default:
+		return 0, false
+	}
+}
+
+// describePrimitive returns a human readable description of a primitive tag.
+func describePrimitive(tag byte) string {
+	width := tag & 0x0F
+	switch tag >> 4 {
+	case primitiveClassBool:
+		return "bool"
+	case primitiveClassInt:
+		return fmt.Sprintf("%d-byte int", width)
+	case primitiveClassUint:
+		return fmt.Sprintf("%d-byte uint", width)
+	case primitiveClassFloat:
+		return fmt.Sprintf("%d-byte float", width)
+	default:
+		return fmt.Sprintf("unknown primitive tag 0x%02x", tag)
+	}
+}
+
+// readPrimitiveTag reads a primitive tag and checks it against the expected one.
+func readPrimitiveTag(reader *Reader, want byte) error {
+	got, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return primitiveMismatch(want, got)
+	}
+	return nil
+}
+
+// primitiveMismatch returns the error for a stream primitive that does not
+// match the target.
+func primitiveMismatch(want, got byte) error {
+	return fmt.Errorf("primitive mismatch: expected %s, stream has %s",
+		describePrimitive(want), describePrimitive(got))
+}
+
+// readTaggedPrimitive reads the tag before a primitive decoded into v. With
+// ReinterpretSignedness, an integer tagged with the same width but the other
+// signedness is accepted, to be read by the caller as v's type. With
+// LenientNumeric, an integer tagged with a different width or signedness is
+// decoded and converted into v, and handled is true.
+func readTaggedPrimitive(reader *Reader, v reflect.Value, want byte) (handled bool, err error) {
+	got, err := reader.ReadByte()
+	if err != nil || got == want {
+		return false, err
+	}
+	if reader.options.ReinterpretSignedness && isIntegerTag(got) && isIntegerTag(want) && got&0x0F == want&0x0F {
+		return false, nil
+	}
+	if !reader.options.LenientNumeric || !isIntegerTag(got) || !isIntegerTag(want) {
+		return false, primitiveMismatch(want, got)
+	}
+
+	bits, err := readIntegerBits(reader, got)
+	if err != nil {
+		return true, err
+	}
+
+	signed := got>>4 == primitiveClassInt
+	if isSignedInt(v.Kind()) {
+		if !signed && bits > math.MaxInt64 || v.OverflowInt(int64(bits)) {
+			return true, fmt.Errorf("%s %s overflows %s", describePrimitive(got), formatBits(bits, signed), v.Type())
+		}
+		v.SetInt(int64(bits))
+		return true, nil
+	}
+	if signed && int64(bits) < 0 || v.OverflowUint(bits) {
+		return true, fmt.Errorf("%s %s overflows %s", describePrimitive(got), formatBits(bits, signed), v.Type())
+	}
+	return true, setUint(v, bits)
+}
+
+// readIntegerBits reads an integer of the width given by tag, sign-extending
+// signed values to 64 bits.
+func readIntegerBits(reader *Reader, tag byte) (uint64, error) {
+	width := int(tag & 0x0F)
+	if width != 1 && width != 2 && width != 4 && width != 8 {
+		return 0, fmt.Errorf("invalid primitive width %d", width)
+	}
+	raw, err := reader.Peek(width)
+	if err != nil {
+		return 0, err
+	}
+	reader.pos += width
+
+	var buf [8]byte
+	copy(buf[:], raw)
+	bits := binary.LittleEndian.Uint64(buf[:])
+	if shift := 64 - 8*width; tag>>4 == primitiveClassInt && shift > 0 {
+		bits = uint64(int64(bits<<shift) >> shift)
+	}
+	return bits, nil
+}
+
+// isIntegerTag reports whether tag describes a signed or unsigned integer.
+func isIntegerTag(tag byte) bool {
+	return tag>>4 == primitiveClassInt || tag>>4 == primitiveClassUint
+}
+
+// formatBits formats an integer read by readTaggedPrimitive.
+func formatBits(bits uint64, signed bool) string {
+	if signed {
+		return strconv.FormatInt(int64(bits), 10)
+	}
+	return strconv.FormatUint(bits, 10)
+}
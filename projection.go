@@ -0,0 +1,96 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Presence bytes written before each field of a projection.
+const (
+	fieldAbsent  byte = 0
+	fieldPresent byte = 1
+)
+
+// SerializeFields serializes only the named fields of a struct.
+//
+// Every field of the struct is preceded by a presence byte, and only the
+// included fields are followed by their value, so the result can be decoded by
+// DeserializeFields into the same struct type. Fields are written in the
+// struct's field order regardless of the order of include.
+func SerializeFields(value any, include []string) ([]byte, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("SerializeFields requires a struct, got %T", value)
+	}
+
+	fd := getFormatterData(v.Type())
+	for _, name := range include {
+		if !slices.ContainsFunc(fd.fields, func(field fieldInfo) bool { return field.name == name }) {
+			return nil, fmt.Errorf("unknown field %q in %s", name, v.Type())
+		}
+	}
+
+	writer := NewWriter(128)
+	if err := writer.WriteObjectHeader(len(fd.fields)); err != nil {
+		return nil, err
+	}
+	for _, field := range fd.fields {
+		if !slices.Contains(include, field.name) {
+			writer.WriteByte(fieldAbsent)
+			continue
+		}
+		writer.WriteByte(fieldPresent)
+		if err := writeField(writer, field, v.Field(field.index)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Err(); err != nil {
+		return nil, err
+	}
+	return writer.GetBytes(), nil
+}
+
+// DeserializeFields deserializes data written by SerializeFields.
+//
+// value must be a pointer to a struct of the serialized type. Fields absent
+// from the data are set to their zero values.
+func DeserializeFields[T any](data []byte, value T) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("DeserializeFields requires a pointer to a struct")
+	}
+	v = v.Elem()
+
+	reader := NewReader(data)
+	fd := getFormatterData(v.Type())
+	fieldCount, isNull, err := reader.ReadObjectHeader()
+	if err != nil || isNull {
+		return err
+	}
+	if fieldCount != len(fd.fields) {
+		return fmt.Errorf("%w during deserialization", ErrFieldCountMismatch)
+	}
+
+	for _, field := range fd.fields {
+		presence, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		fieldValue := v.Field(field.index)
+		switch presence {
+		case fieldAbsent:
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		case fieldPresent:
+			if err = readField(reader, field, fieldValue); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid presence byte %d for field %s", presence, field.name)
+		}
+	}
+	return nil
+}
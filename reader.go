@@ -3,10 +3,17 @@ package memorypack
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
+	"slices"
 )
 
+// streamChunkSize bounds how much a stream-backed Reader requests from its
+// source at once, so that a corrupt length cannot force a huge allocation
+// before the data has actually arrived.
+const streamChunkSize = 64 * 1024
+
 // Deserialize deserializes a value from a byte slice.
 //
 // value must be a pointer to a value.
@@ -15,10 +22,69 @@ import (
 //
 // Otherwise, the value will be deserialized using reflection.
 func Deserialize[T any](data []byte, value T) error {
-	reader := NewReader(data)
+	return DeserializeWithOptions(data, value, Options{})
+}
+
+// DeserializeWithOptions deserializes a value from a byte slice using the given options.
+//
+// The options must match the ones the data was serialized with.
+func DeserializeWithOptions[T any](data []byte, value T, opts Options) error {
+	return decode(NewReaderWithOptions(data, opts), value)
+}
+
+// DeserializeReset sets *value to its zero value and then deserializes data
+// into it using the given options, so that a reused object, such as one from
+// a pool, keeps nothing from its previous contents. Without the reset, fields
+// the data leaves out, as under FieldPresence or KeyedFields with
+// AllowMissingFields, keep their old values.
+func DeserializeReset[T any](data []byte, value *T, opts Options) error {
+	if value == nil {
+		return fmt.Errorf("deserialize requires a non-nil pointer, got nil %T", value)
+	}
+	var zero T
+	*value = zero
+	return decode(NewReaderWithOptions(data, opts), value)
+}
+
+// DeserializeValue deserializes data into v, which must be settable.
+//
+// Data written by Serialize(&x) decodes into reflect.ValueOf(&x).Elem().
+func DeserializeValue(data []byte, v reflect.Value) error {
+	if !v.CanSet() {
+		return fmt.Errorf("deserialize requires a settable value, got %s", v.Kind())
+	}
+	return readValue(NewReader(data), v)
+}
+
+// ReadValue reads a nested value of type T from r, as written by WriteValue.
+func ReadValue[T any](r *Reader) (T, error) {
+	var v T
+	err := readValue(r, reflect.ValueOf(&v).Elem())
+	return v, err
+}
+
+// decode deserializes value from the reader.
+func decode(reader *Reader, value any) error {
+	if reader.options.TypeIDPrefix {
+		var err error
+		if value, err = readTypeID(reader, value); err != nil {
+			return err
+		}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return fmt.Errorf("deserialize requires a non-nil pointer, got nil %s", v.Type())
+	}
+	if reader.options.TrackReferences && v.Kind() == reflect.Ptr {
+		// Reference 0 is the top-level pointer, as registered by encode.
+		if reader.refs == nil {
+			reader.refs = &referenceTable{}
+		}
+		reader.refs.values = append(reader.refs.values, v)
+	}
 
 	// Use reflection to check if value implements Formatter
-	formatter, ok := any(value).(Formatter)
+	formatter, ok := value.(Formatter)
 	if ok {
 		if err := formatter.Deserialize(reader); err != nil {
 			return fmt.Errorf("deserialize failed: %w", err)
@@ -26,29 +92,106 @@ func Deserialize[T any](data []byte, value T) error {
 		return nil
 	}
 
-	v := reflect.ValueOf(value)
 	if v.Kind() != reflect.Ptr {
 		return fmt.Errorf("deserialize requires a pointer to a value")
 	}
+	if err := readValue(reader, v.Elem()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeserializeWithDefaults deserializes a value whose structs may have been
+// written with fewer fields than they now declare.
+//
+// For each missing field, defaults is called with the field name. If it reports
+// a value, that value is assigned to the field; otherwise the field is zeroed.
+func DeserializeWithDefaults[T any](data []byte, value T, defaults func(fieldName string) (any, bool)) error {
+	reader := NewReaderWithOptions(data, Options{AllowMissingFields: true})
+	reader.defaults = defaults
+	return decode(reader, value)
+}
+
+// DeserializeWithProgress deserializes a value, calling progress with the
+// number of bytes read so far as elements of each collection are decoded.
+// Successive calls report strictly increasing counts, ending with the total.
+func DeserializeWithProgress[T any](data []byte, value T, progress func(bytesRead int)) error {
+	reader := NewReader(data)
+	reader.progress = progress
+	if err := decode(reader, value); err != nil {
+		return err
+	}
+	reader.reportProgress()
+	return nil
+}
+
+// DeserializePrefix deserializes only the first maxFields fields of a struct.
+//
+// value must be a pointer to a struct. The remaining fields are set to their zero
+// values. It returns the number of fields decoded and the offset in data where
+// decoding stopped, so that a caller can resume reading from there later.
+func DeserializePrefix[T any](data []byte, value T, maxFields int) (int, int, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return 0, 0, fmt.Errorf("DeserializePrefix requires a pointer to a struct")
+	}
 	v = v.Elem()
 
-	if v.Kind() == reflect.Struct {
-		if err := deserializeStruct(reader, value); err != nil {
-			return err
+	reader := NewReader(data)
+	fd := getFormatterData(v.Type())
+
+	fieldCount, isNull, err := reader.ReadObjectHeader()
+	if err != nil {
+		return 0, reader.pos, err
+	}
+	if isNull {
+		return 0, reader.pos, nil
+	}
+	if fieldCount != len(fd.fields) {
+		return 0, reader.pos, fmt.Errorf("%w during deserialization", ErrFieldCountMismatch)
+	}
+
+	n := max(0, min(maxFields, fieldCount))
+	for i, field := range fd.fields {
+		fieldValue := v.Field(field.index)
+		if i >= n {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+			continue
 		}
-	} else {
-		if err := readValue(reader, v); err != nil {
-			return err
+		if err = readField(reader, field, fieldValue); err != nil {
+			return i, reader.pos, err
 		}
 	}
 
-	return nil
+	return n, reader.pos, nil
 }
 
 // Reader handles deserialization of data from a binary format.
 type Reader struct {
-	buffer []byte
-	pos    int
+	buffer  []byte
+	pos     int
+	options Options
+	src     io.Reader
+	refs    *referenceTable
+
+	// defaults supplies values for struct fields missing from the stream.
+	defaults func(fieldName string) (any, bool)
+
+	// filtered caches formatter data restricted by options.FieldFilter and
+	// TransientFields.
+	filtered map[reflect.Type]formatterData
+
+	// allocated counts the bytes charged against options.MaxAllocBytes.
+	allocated *int64
+
+	// depth is the nesting depth of the value being read.
+	depth int
+
+	// progress, if set, receives the read position after collection elements;
+	// reported is the last position passed to it.
+	progress func(bytesRead int)
+	reported int
 }
 
 // NewReader creates a new MemoryPack reader.
@@ -59,6 +202,130 @@ func NewReader(data []byte) *Reader {
 	}
 }
 
+// NewReaderWithOptions creates a new MemoryPack reader that decodes using the given options.
+func NewReaderWithOptions(data []byte, opts Options) *Reader {
+	r := NewReader(data)
+	r.options = opts
+	return r
+}
+
+// fork returns a reader for decoding a nested payload held in data.
+// It shares the options, defaults and tracked references of r.
+func (r *Reader) fork(data []byte) *Reader {
+	if r.refs == nil {
+		r.refs = &referenceTable{}
+	}
+	sub := NewReaderWithOptions(data, r.options)
+	sub.refs = r.refs
+	sub.defaults = r.defaults
+	sub.filtered = r.filtered
+	if r.allocated == nil && r.options.MaxAllocBytes > 0 {
+		r.allocated = new(int64)
+	}
+	sub.allocated = r.allocated
+	sub.depth = r.depth
+	return sub
+}
+
+// reportProgress passes the read position to the progress callback if it has
+// advanced since the last call.
+func (r *Reader) reportProgress() {
+	if r.progress != nil && r.pos > r.reported {
+		r.reported = r.pos
+		r.progress(r.pos)
+	}
+}
+
+// enterDepth increments the nesting depth, failing beyond MaxDepth so that
+// deeply nested input cannot exhaust the stack.
+func (r *Reader) enterDepth() error {
+	r.depth++
+	if r.depth > MaxDepth {
+		return fmt.Errorf("deserialization %w %d", ErrDepthExceeded, MaxDepth)
+	}
+	return nil
+}
+
+// checkLength fails if a collection of length elements of type elem cannot
+// fit in the remaining data, before it is allocated. Every encoded value takes
+// at least one byte, and tuple structs at least the widths of their fixed-size
+// fields, except values of zero-size types, which cost nothing to allocate.
+// Stream readers pull the bytes in to check them.
+func (r *Reader) checkLength(length int, elem reflect.Type) error {
+	if elem.Size() == 0 {
+		return nil
+	}
+	size := 1
+	if isTupleStruct(elem, &r.options) {
+		size = max(r.tupleMinSize(elem), 1)
+	}
+	if length > math.MaxInt/size || !r.ensure(length*size) {
+		return fmt.Errorf("collection length %d of elements of at least %d bytes exceeds the %d bytes remaining: %w",
+			length, size, len(r.buffer)-r.pos, ErrTruncated)
+	}
+	return nil
+}
+
+// tupleMinSize returns the fewest bytes a tuple struct of type t can take: the
+// sum of the widths of its fixed-size fields, or its presence bitmap when
+// fields may be absent. Other fields count as one byte, or none for zero-size
+// types.
+func (r *Reader) tupleMinSize(t reflect.Type) int {
+	fd := filterFormatterData(t, &r.options, &r.filtered)
+	if r.options.FieldPresence {
+		return (len(fd.fields) + 7) / 8
+	}
+	size := 0
+	for _, field := range fd.fields {
+		ft := t.Field(field.index).Type
+		switch kind := ft.Kind(); {
+		case ft.Size() == 0:
+		case field.transformed() || isKnownType(ft) || isEnumType(ft) || isOptionalType(ft) || isFormatterType(ft):
+			size++
+		case isTupleStruct(ft, &r.options):
+			size += r.tupleMinSize(ft)
+		case kind == reflect.Bool || isSignedInt(kind) || isUnsignedInt(kind) || kind == reflect.Float32 || kind == reflect.Float64:
+			if r.options.TaggedPrimitives {
+				// A tag and at least one byte, since tags may narrow the value.
+				size += 2
+			} else {
+				size += int(ft.Size())
+			}
+		default:
+			size++
+		}
+	}
+	return size
+}
+
+// ensure reports whether at least n unread bytes are available, pulling more
+// data from the underlying stream when the reader has one.
+func (r *Reader) ensure(n int) bool {
+	if n < 0 {
+		return false
+	}
+	need := n - (len(r.buffer) - r.pos)
+	if need <= 0 {
+		return true
+	}
+	if r.src == nil {
+		return false
+	}
+
+	for need > 0 {
+		chunk := min(need, streamChunkSize)
+		start := len(r.buffer)
+		r.buffer = slices.Grow(r.buffer, chunk)[:start+chunk]
+		read, err := io.ReadFull(r.src, r.buffer[start:])
+		r.buffer = r.buffer[:start+read]
+		if err != nil {
+			return false
+		}
+		need -= read
+	}
+	return true
+}
+
 // ReadFormatVersion reads the MemoryPack format version.
 func (r *Reader) ReadFormatVersion() (byte, error) {
 	return r.ReadByte()
@@ -66,8 +333,8 @@ func (r *Reader) ReadFormatVersion() (byte, error) {
 
 // ReadByte reads a byte from the buffer.
 func (r *Reader) ReadByte() (byte, error) {
-	if r.pos >= len(r.buffer) {
-		return 0, fmt.Errorf("cannot read byte: end of buffer")
+	if !r.ensure(1) {
+		return 0, fmt.Errorf("cannot read byte: %w", ErrTruncated)
 	}
 
 	v := r.buffer[r.pos]
@@ -77,13 +344,46 @@ func (r *Reader) ReadByte() (byte, error) {
 
 // Peek reads the next n bytes without advancing the position.
 func (r *Reader) Peek(n int) ([]byte, error) {
-	if r.pos+n > len(r.buffer) {
-		return nil, fmt.Errorf("cannot peek %d bytes: end of buffer", n)
+	if !r.ensure(n) {
+		return nil, fmt.Errorf("cannot peek %d bytes: %w", n, ErrTruncated)
 	}
 
 	return r.buffer[r.pos : r.pos+n], nil
 }
 
+// Discard skips the next n bytes and returns the number of bytes skipped.
+//
+// If fewer than n bytes remain, Discard skips them all and returns an error.
+func (r *Reader) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("cannot discard %d bytes", n)
+	}
+	if !r.ensure(n) {
+		skipped := len(r.buffer) - r.pos
+		r.pos = len(r.buffer)
+		return skipped, fmt.Errorf("cannot discard %d bytes: only %d bytes available: %w", n, skipped, ErrTruncated)
+	}
+	r.pos += n
+	return n, nil
+}
+
+// IsNull reports whether the next byte is a null marker without consuming it.
+func (r *Reader) IsNull() bool {
+	return r.ensure(1) && r.buffer[r.pos] == NullObject
+}
+
+// ReadNull consumes a null marker written by Writer.WriteNull.
+func (r *Reader) ReadNull() error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != NullObject {
+		return fmt.Errorf("expected null marker, got 0x%02x", b)
+	}
+	return nil
+}
+
 // ReadBytes reads a byte slice from the buffer.
 func (r *Reader) ReadBytes() ([]byte, error) {
 	length, err := r.ReadInt32()
@@ -96,25 +396,49 @@ func (r *Reader) ReadBytes() ([]byte, error) {
 	}
 
 	if length < 0 {
-		return nil, fmt.Errorf("invalid byte array length: %d", length)
+		return nil, fmt.Errorf("%w: byte array length %d", ErrInvalidHeader, length)
 	}
 
 	// Bounds check
-	if int(length) > len(r.buffer)-r.pos {
-		return nil, fmt.Errorf("read error: requested %d bytes but only %d bytes available",
-			length, len(r.buffer)-r.pos)
+	if !r.ensure(int(length)) {
+		return nil, fmt.Errorf("read error: requested %d bytes but only %d bytes available: %w",
+			length, len(r.buffer)-r.pos, ErrTruncated)
 	}
 
+	if err = r.allocate(int(length), 1); err != nil {
+		return nil, err
+	}
 	result := make([]byte, length)
 	copy(result, r.buffer[r.pos:r.pos+int(length)])
 	r.pos += int(length)
 	return result, nil
 }
 
+// ReadSubReader reads an int32 length and returns a reader scoped to exactly
+// that many following bytes. The parent reader advances past them.
+func (r *Reader) ReadSubReader() (*Reader, error) {
+	length, err := r.ReadInt32()
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("%w: sub-reader length %d", ErrInvalidHeader, length)
+	}
+	if !r.ensure(int(length)) {
+		return nil, fmt.Errorf("read error: requested %d bytes but only %d bytes available: %w",
+			length, len(r.buffer)-r.pos, ErrTruncated)
+	}
+
+	end := r.pos + int(length)
+	sub := r.fork(r.buffer[r.pos:end:end])
+	r.pos = end
+	return sub, nil
+}
+
 // ReadInt16 reads an int16 from the buffer.
 func (r *Reader) ReadInt16() (int16, error) {
-	if r.pos+2 > len(r.buffer) {
-		return 0, fmt.Errorf("cannot read int16: end of buffer")
+	if !r.ensure(2) {
+		return 0, fmt.Errorf("cannot read int16: %w", ErrTruncated)
 	}
 	v := binary.LittleEndian.Uint16(r.buffer[r.pos:])
 	r.pos += 2
@@ -123,8 +447,8 @@ func (r *Reader) ReadInt16() (int16, error) {
 
 // ReadInt32 reads an int32 from the buffer.
 func (r *Reader) ReadInt32() (int32, error) {
-	if r.pos+4 > len(r.buffer) {
-		return 0, fmt.Errorf("cannot read int32: end of buffer")
+	if !r.ensure(4) {
+		return 0, fmt.Errorf("cannot read int32: %w", ErrTruncated)
 	}
 	v := binary.LittleEndian.Uint32(r.buffer[r.pos:])
 	r.pos += 4
@@ -133,18 +457,36 @@ func (r *Reader) ReadInt32() (int32, error) {
 
 // ReadInt64 reads an int64 from the buffer.
 func (r *Reader) ReadInt64() (int64, error) {
-	if r.pos+8 > len(r.buffer) {
-		return 0, fmt.Errorf("cannot read int64: end of buffer")
+	if !r.ensure(8) {
+		return 0, fmt.Errorf("cannot read int64: %w", ErrTruncated)
 	}
 	v := binary.LittleEndian.Uint64(r.buffer[r.pos:])
 	r.pos += 8
 	return int64(v), nil
 }
 
+// ReadVarint reads a zigzag-encoded signed varint from the buffer.
+func (r *Reader) ReadVarint() (int64, error) {
+	v, err := binary.ReadVarint(r)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read varint: %w", err)
+	}
+	return v, nil
+}
+
+// ReadUvarint reads an unsigned varint from the buffer.
+func (r *Reader) ReadUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read uvarint: %w", err)
+	}
+	return v, nil
+}
+
 // ReadFloat32 reads a float32 from the buffer.
 func (r *Reader) ReadFloat32() (float32, error) {
-	if r.pos+4 > len(r.buffer) {
-		return 0, fmt.Errorf("cannot read float32: end of buffer")
+	if !r.ensure(4) {
+		return 0, fmt.Errorf("cannot read float32: %w", ErrTruncated)
 	}
 	v := binary.LittleEndian.Uint32(r.buffer[r.pos:])
 	r.pos += 4
@@ -153,8 +495,8 @@ func (r *Reader) ReadFloat32() (float32, error) {
 
 // ReadFloat64 reads a float64 from the buffer.
 func (r *Reader) ReadFloat64() (float64, error) {
-	if r.pos+8 > len(r.buffer) {
-		return 0, fmt.Errorf("cannot read float64: end of buffer")
+	if !r.ensure(8) {
+		return 0, fmt.Errorf("cannot read float64: %w", ErrTruncated)
 	}
 	v := binary.LittleEndian.Uint64(r.buffer[r.pos:])
 	r.pos += 8
@@ -172,6 +514,12 @@ func (r *Reader) ReadBool() (bool, error) {
 
 // ReadString reads a string from the buffer using MemoryPack format.
 func (r *Reader) ReadString() (string, error) {
+	if r.options.CompactStrings {
+		if v, short, err := r.readShortString(); short || err != nil {
+			return v, err
+		}
+	}
+
 	// Read the header
 	byteCount, err := r.ReadInt32()
 	if err != nil {
@@ -189,6 +537,9 @@ func (r *Reader) ReadString() (string, error) {
 
 	// It's a normal string, the byteCount is negated (~)
 	actualByteCount := ^byteCount
+	if limit := r.options.MaxStringLength; limit > 0 && int(actualByteCount) > limit {
+		return "", fmt.Errorf("string length %d exceeds the limit of %d bytes", actualByteCount, limit)
+	}
 
 	// Read the string length (UTF-16 length in C#)
 	_, err = r.ReadInt32() // Skip this in Go since we don't need it
@@ -197,16 +548,44 @@ func (r *Reader) ReadString() (string, error) {
 	}
 
 	// Read the UTF-8 bytes
-	if r.pos+int(actualByteCount) > len(r.buffer) {
-		return "", fmt.Errorf("read error: requested %d bytes for string but only %d bytes available",
-			actualByteCount, len(r.buffer)-r.pos)
+	if !r.ensure(int(actualByteCount)) {
+		return "", fmt.Errorf("read error: requested %d bytes for string but only %d bytes available: %w",
+			actualByteCount, len(r.buffer)-r.pos, ErrTruncated)
 	}
 
+	if err = r.allocate(int(actualByteCount), 1); err != nil {
+		return "", err
+	}
 	str := string(r.buffer[r.pos : r.pos+int(actualByteCount)])
 	r.pos += int(actualByteCount)
 	return str, nil
 }
 
+// ReadStringRaw reads a string written by Writer.WriteStringRaw.
+func (r *Reader) ReadStringRaw() (string, error) {
+	length, err := r.ReadInt32()
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", fmt.Errorf("%w: string length %d", ErrInvalidHeader, length)
+	}
+	if limit := r.options.MaxStringLength; limit > 0 && int(length) > limit {
+		return "", fmt.Errorf("string length %d exceeds the limit of %d bytes", length, limit)
+	}
+	if !r.ensure(int(length)) {
+		return "", fmt.Errorf("read error: requested %d bytes for string but only %d bytes available: %w",
+			length, len(r.buffer)-r.pos, ErrTruncated)
+	}
+	if err = r.allocate(int(length), 1); err != nil {
+		return "", err
+	}
+
+	str := string(r.buffer[r.pos : r.pos+int(length)])
+	r.pos += int(length)
+	return str, nil
+}
+
 // ReadCollectionHeader reads a collection header and returns the length.
 func (r *Reader) ReadCollectionHeader() (int, bool, error) {
 	length, err := r.ReadInt32()
@@ -216,17 +595,65 @@ func (r *Reader) ReadCollectionHeader() (int, bool, error) {
 	if length == NullCollection {
 		return 0, true, nil // null collection
 	}
+	if length < 0 {
+		return 0, false, fmt.Errorf("%w: collection length %d", ErrInvalidHeader, length)
+	}
 	return int(length), false, nil // non-null collection
 }
 
-// ReadObjectHeader reads an object header.
+// ReadObjectHeader reads an object header. Headers 250 to 254 are reserved for
+// unions and references and are rejected.
 func (r *Reader) ReadObjectHeader() (int, bool, error) {
 	header, err := r.ReadByte()
 	if err != nil {
 		return 0, false, err
 	}
-	if header == NullObject {
+	switch {
+	case header == NullObject:
 		return 0, true, nil // null object
+	case header > 249:
+		return 0, false, fmt.Errorf("%w: object header %d", ErrInvalidHeader, header)
+	default:
+		return int(header), false, nil // member count
+	}
+}
+
+// PeekMemberCount returns the member count and null flag of the object header
+// at the start of data, without decoding its members. Headers 250 to 254 are
+// reserved for unions and references and are rejected.
+func PeekMemberCount(data []byte) (int, bool, error) {
+	if len(data) == 0 {
+		return 0, false, fmt.Errorf("cannot read object header: %w", ErrTruncated)
+	}
+	header := data[0]
+	switch {
+	case header == NullObject:
+		return 0, true, nil
+	case header > 249:
+		return 0, false, fmt.Errorf("%w: object header %d", ErrInvalidHeader, header)
+	default:
+		return int(header), false, nil
+	}
+}
+
+// ReadUnionHeader reads a union header and returns the tag.
+func (r *Reader) ReadUnionHeader() (uint16, bool, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case header == NullObject:
+		return 0, true, nil // null union
+	case header == WideTag:
+		tag, err := r.ReadInt16()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint16(tag), false, nil
+	case header > WideTag:
+		return 0, false, fmt.Errorf("%w: union header %d", ErrInvalidHeader, header)
+	default:
+		return uint16(header), false, nil
 	}
-	return int(header), false, nil // member count
 }
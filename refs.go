@@ -0,0 +1,170 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// refKey identifies a pointer or slice for reference tracking. The type is part
+// of the key because a struct and its first field share the same address.
+type refKey struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// referenceTable holds the pointers decoded so far, indexed by reference ID.
+type referenceTable struct {
+	values []reflect.Value
+}
+
+// registerRoot reserves reference ID 0 for the top-level value, so that
+// pointers back to it, such as a child listing its parent, decode as the
+// pointer passed to Deserialize. Values not passed by pointer reserve the ID
+// without being referable.
+func registerRoot(writer *Writer, v reflect.Value) {
+	if writer.refs == nil {
+		writer.refs = make(map[refKey]int)
+	}
+	var key refKey
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		key = refKey{ptr: v.Pointer(), typ: v.Type()}
+	}
+	writer.refs[key] = len(writer.refs)
+}
+
+// writeReference writes a non-nil pointer with reference tracking. The first
+// occurrence is written in full; later ones refer back to it by ID.
+func writeReference(writer *Writer, v reflect.Value) error {
+	if writer.refs == nil {
+		writer.refs = make(map[refKey]int)
+	}
+
+	key := refKey{ptr: v.Pointer(), typ: v.Type()}
+	if id, seen := writer.refs[key]; seen {
+		writer.WriteByte(ReferenceID)
+		writer.WriteUvarint(uint64(id))
+		return nil
+	}
+
+	// Register before descending so that cycles back to v resolve.
+	writer.refs[key] = len(writer.refs)
+	writer.WriteByte(ReferenceNew)
+	return writeValue(writer, v.Elem())
+}
+
+// readReference reads a pointer written by writeReference.
+func readReference(reader *Reader, v reflect.Value) error {
+	if reader.refs == nil {
+		reader.refs = &referenceTable{}
+	}
+
+	header, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch header {
+	case NullObject:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case ReferenceID:
+		id, err := reader.ReadUvarint()
+		if err != nil {
+			return err
+		}
+		if id >= uint64(len(reader.refs.values)) {
+			return fmt.Errorf("invalid reference id %d", id)
+		}
+		ref := reader.refs.values[id]
+		if !ref.IsValid() {
+			return fmt.Errorf("reference %d refers to a slice that is still being decoded", id)
+		}
+		if ref.Type() != v.Type() {
+			return fmt.Errorf("reference %d has type %s, expected %s", id, ref.Type(), v.Type())
+		}
+		v.Set(ref)
+		return nil
+	case ReferenceNew:
+		if err = reader.allocate(1, v.Type().Elem().Size()); err != nil {
+			return err
+		}
+		ptr := reflect.New(v.Type().Elem())
+		reader.refs.values = append(reader.refs.values, ptr)
+		v.Set(ptr)
+		return readValue(reader, ptr.Elem())
+	default:
+		return fmt.Errorf("%w: reference header %d", ErrInvalidHeader, header)
+	}
+}
+
+// writeSharedSlice writes a slice with ShareSlices. The first occurrence of a
+// slice header is written in full; later ones refer back to it by ID.
+func writeSharedSlice(writer *Writer, v reflect.Value) error {
+	if v.IsNil() {
+		writer.WriteByte(NullObject)
+		return nil
+	}
+	if writer.refs == nil {
+		writer.refs = make(map[refKey]int)
+	}
+
+	key := refKey{ptr: v.Pointer(), len: v.Len(), typ: v.Type()}
+	if id, seen := writer.refs[key]; seen {
+		writer.WriteByte(ReferenceID)
+		writer.WriteUvarint(uint64(id))
+		return nil
+	}
+
+	writer.refs[key] = len(writer.refs)
+	writer.WriteByte(ReferenceNew)
+	return writeSlice(writer, v)
+}
+
+// readSharedSlice reads a slice written by writeSharedSlice.
+func readSharedSlice(reader *Reader, v reflect.Value) error {
+	if reader.refs == nil {
+		reader.refs = &referenceTable{}
+	}
+
+	header, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch header {
+	case NullObject:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case ReferenceID:
+		id, err := reader.ReadUvarint()
+		if err != nil {
+			return err
+		}
+		if id >= uint64(len(reader.refs.values)) {
+			return fmt.Errorf("invalid reference id %d", id)
+		}
+		ref := reader.refs.values[id]
+		if !ref.IsValid() {
+			return fmt.Errorf("reference %d refers to a slice that is still being decoded", id)
+		}
+		if ref.Type() != v.Type() {
+			return fmt.Errorf("reference %d has type %s, expected %s", id, ref.Type(), v.Type())
+		}
+		v.Set(ref)
+		return nil
+	case ReferenceNew:
+		// Reserve the ID now, matching the order the writer assigned them in.
+		id := len(reader.refs.values)
+		reader.refs.values = append(reader.refs.values, reflect.Value{})
+		if err = readSlice(reader, v); err != nil {
+			return err
+		}
+		ref := reflect.New(v.Type()).Elem()
+		ref.Set(v)
+		reader.refs.values[id] = ref
+		return nil
+	default:
+		return fmt.Errorf("%w: reference header %d", ErrInvalidHeader, header)
+	}
+}
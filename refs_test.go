@@ -0,0 +1,188 @@
+package memorypack_test
+
+import (
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type refNode struct {
+	Name string
+	Next *refNode
+}
+
+// TestTrackReferences tests identity preservation of shared pointers.
+func TestTrackReferences(t *testing.T) {
+	opts := memorypack.Options{TrackReferences: true}
+
+	t.Run("SharedMapValues", func(t *testing.T) {
+		shared := &refNode{Name: "shared"}
+		original := map[string]*refNode{"a": shared, "b": shared, "c": {Name: "other"}, "d": nil}
+
+		data, err := memorypack.SerializeWithOptions(&original, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result map[string]*refNode
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+
+		if result["a"] == nil || result["a"] != result["b"] {
+			t.Errorf("Expected a and b to share one pointer, got %p and %p", result["a"], result["b"])
+		}
+		if result["a"] == result["c"] || result["c"].Name != "other" {
+			t.Errorf("Expected c to be a distinct node, got %+v", result["c"])
+		}
+		if result["d"] != nil {
+			t.Errorf("Expected d to be nil, got %+v", result["d"])
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		a := &refNode{Name: "A"}
+		b := &refNode{Name: "B", Next: a}
+		a.Next = b
+		original := []*refNode{a}
+
+		data, err := memorypack.SerializeWithOptions(&original, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result []*refNode
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+
+		ra := result[0]
+		if ra.Name != "A" || ra.Next.Name != "B" || ra.Next.Next != ra {
+			t.Errorf("Cycle not preserved: %+v -> %+v -> %p", ra, ra.Next, ra.Next.Next)
+		}
+	})
+
+	t.Run("SelfIncludingSlice", func(t *testing.T) {
+		type tree struct {
+			Name     string
+			Children []*tree
+		}
+
+		root := &tree{Name: "root"}
+		child := &tree{Name: "child", Children: []*tree{root}}
+		root.Children = []*tree{child, root}
+
+		data, err := memorypack.SerializeWithOptions(root, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result tree
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+
+		if len(result.Children) != 2 || result.Children[1] != &result {
+			t.Fatalf("Expected root to include itself, got %+v", result.Children)
+		}
+		rc := result.Children[0]
+		if rc.Name != "child" || len(rc.Children) != 1 || rc.Children[0] != &result {
+			t.Errorf("Expected child to point back to root, got %+v", rc)
+		}
+	})
+
+	t.Run("AdjacencyMap", func(t *testing.T) {
+		type vertex struct {
+			Name  string
+			Edges []*vertex
+		}
+		type graph struct {
+			Nodes map[string]*vertex
+		}
+
+		a, b, c := &vertex{Name: "a"}, &vertex{Name: "b"}, &vertex{Name: "c"}
+		a.Edges = []*vertex{b}
+		b.Edges = []*vertex{c}
+		c.Edges = []*vertex{a, b}
+		original := &graph{Nodes: map[string]*vertex{"a": a, "b": b, "c": c}}
+
+		data, err := memorypack.SerializeWithOptions(original, opts)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+
+		var result graph
+		if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+			t.Fatalf("Deserialize failed: %v", err)
+		}
+
+		ra, rb, rc := result.Nodes["a"], result.Nodes["b"], result.Nodes["c"]
+		if ra == nil || rb == nil || rc == nil {
+			t.Fatalf("Missing nodes: %v", result.Nodes)
+		}
+		if ra.Name != "a" || rb.Name != "b" || rc.Name != "c" {
+			t.Errorf("Unexpected names %q %q %q", ra.Name, rb.Name, rc.Name)
+		}
+		if len(ra.Edges) != 1 || ra.Edges[0] != rb {
+			t.Error("Expected a -> b to point at the map entry for b")
+		}
+		if len(rb.Edges) != 1 || rb.Edges[0] != rc {
+			t.Error("Expected b -> c to point at the map entry for c")
+		}
+		if len(rc.Edges) != 2 || rc.Edges[0] != ra || rc.Edges[1] != rb {
+			t.Error("Expected c -> a, b to close the cycle")
+		}
+	})
+
+	t.Run("InvalidReference", func(t *testing.T) {
+		data := []byte{memorypack.ReferenceID, 5}
+
+		var result *refNode
+		if err := memorypack.DeserializeWithOptions(data, &result, opts); err == nil {
+			t.Error("Expected error for unknown reference id, got nil")
+		}
+	})
+}
+
+// TestShareSlices tests deduplication of struct fields holding the same slice.
+func TestShareSlices(t *testing.T) {
+	type Mesh struct {
+		Vertices []float32
+		Normals  []float32
+		Name     string
+		Indices  []int32
+		Outline  []int32
+	}
+	opts := memorypack.Options{ShareSlices: true}
+
+	vertices := make([]float32, 300)
+	for i := range vertices {
+		vertices[i] = float32(i)
+	}
+	indices := []int32{0, 1, 2, 2, 3, 0}
+	original := Mesh{Vertices: vertices, Normals: vertices, Name: "quad", Indices: indices, Outline: indices[:4]}
+
+	shared := testRoundTripWithOptions(t, original, opts)
+	plain, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if saved := len(plain) - len(shared); saved < 4*len(vertices)-8 {
+		t.Errorf("Expected the aliased slice to be written once: %d bytes shared, %d plain", len(shared), len(plain))
+	}
+
+	var result Mesh
+	if err = memorypack.DeserializeWithOptions(shared, &result, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if &result.Vertices[0] != &result.Normals[0] {
+		t.Error("Expected Vertices and Normals to share a backing array")
+	}
+	// Different lengths of the same array are separate slices.
+	if &result.Indices[0] == &result.Outline[0] {
+		t.Error("Expected Indices and Outline to be decoded separately")
+	}
+
+	testRoundTripWithOptions(t, Mesh{}, opts)
+	testRoundTripWithOptions(t, [][]string{{"a"}, nil, {}}, opts)
+}
@@ -0,0 +1,204 @@
+package memorypack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// builtinTagBase is the first union tag reserved for built-in types.
+const builtinTagBase uint16 = 0xFF00
+
+var (
+	registryMu sync.Mutex
+	typeByTag  sync.Map // map[uint16]reflect.Type
+	tagByType  sync.Map // map[reflect.Type]uint16
+)
+
+// builtinTypes lists the types that can be stored in interface values without
+// registration. Each is tagged with builtinTagBase plus its index, so new types
+// must only ever be appended.
+var builtinTypes = []reflect.Type{
+	reflect.TypeFor[bool](),
+	reflect.TypeFor[int](),
+	reflect.TypeFor[int8](),
+	reflect.TypeFor[int16](),
+	reflect.TypeFor[int32](),
+	reflect.TypeFor[int64](),
+	reflect.TypeFor[float32](),
+	reflect.TypeFor[float64](),
+	reflect.TypeFor[string](),
+	reflect.TypeFor[[]byte](),
+	reflect.TypeFor[[]any](),
+	reflect.TypeFor[map[string]any](),
+	reflect.TypeFor[uint](),
+	reflect.TypeFor[uint8](),
+	reflect.TypeFor[uint16](),
+	reflect.TypeFor[uint32](),
+	reflect.TypeFor[uint64](),
+	reflect.TypeFor[time.Time](),
+}
+
+func init() {
+	for i, t := range builtinTypes {
+		if err := registerType(builtinTagBase+uint16(i), t); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterType registers a concrete type under a union tag so that its values
+// can be stored in interface-typed fields, slices and map values.
+//
+// Tags from 0xFF00 upwards are reserved for built-in types. Registering the same
+// type under the same tag again is a no-op.
+func RegisterType(tag uint16, t reflect.Type) error {
+	if tag >= builtinTagBase {
+		return fmt.Errorf("union tag %d is reserved for built-in types", tag)
+	}
+	return registerType(tag, t)
+}
+
+// registerType records the tag for t without checking for reserved tags.
+func registerType(tag uint16, t reflect.Type) error {
+	if t == nil || t.Kind() == reflect.Interface {
+		return fmt.Errorf("cannot register %v: union members must be concrete types", t)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if existing, found := typeByTag.Load(tag); found {
+		if existing.(reflect.Type) == t {
+			return nil
+		}
+		return fmt.Errorf("union tag %d is already registered for %s", tag, existing)
+	}
+	if existing, found := tagByType.Load(t); found {
+		return fmt.Errorf("type %s is already registered with union tag %d", t, existing)
+	}
+
+	typeByTag.Store(tag, t)
+	tagByType.Store(t, tag)
+	return nil
+}
+
+// writeInterface writes an interface value as a union of its dynamic type.
+func writeInterface(writer *Writer, v reflect.Value) error {
+	if v.IsNil() {
+		writer.WriteByte(NullObject)
+		return nil
+	}
+
+	elem := v.Elem()
+	tag, found := tagByType.Load(elem.Type())
+	if !found {
+		return fmt.Errorf("type %s is not registered for use in interface values", elem.Type())
+	}
+
+	writer.WriteUnionHeader(tag.(uint16))
+	return writeValue(writer, elem)
+}
+
+// readInterface reads a union written by writeInterface into an interface value.
+func readInterface(reader *Reader, v reflect.Value) error {
+	tag, isNull, err := reader.ReadUnionHeader()
+	if err != nil {
+		return err
+	}
+	if isNull {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	registered, found := typeByTag.Load(tag)
+	if !found {
+		return fmt.Errorf("union tag %d is not registered", tag)
+	}
+	t := registered.(reflect.Type)
+	if !t.AssignableTo(v.Type()) {
+		return fmt.Errorf("type %s with union tag %d does not implement %s", t, tag, v.Type())
+	}
+
+	elem := reflect.New(t).Elem()
+	if err = readValue(reader, elem); err != nil {
+		return err
+	}
+	v.Set(elem)
+	return nil
+}
+
+// typeIDSize is the length of the prefix written under Options.TypeIDPrefix.
+const typeIDSize = 4
+
+// PeekType returns the type ID prefixed to data by Options.TypeIDPrefix,
+// without decoding the value that follows.
+func PeekType(data []byte) (uint32, error) {
+	if len(data) < typeIDSize {
+		return 0, fmt.Errorf("data too short for a type ID: %d bytes: %w", len(data), ErrTruncated)
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+// typeIDOf returns the registered type ID for t, the type of a top-level
+// value or the type a top-level pointer points to.
+func typeIDOf(t reflect.Type) (uint32, error) {
+	if t == nil {
+		return 0, fmt.Errorf("cannot determine the type ID of a nil value")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	tag, found := tagByType.Load(t)
+	if !found {
+		return 0, fmt.Errorf("type %s is not registered for a type ID prefix", t)
+	}
+	return uint32(tag.(uint16)), nil
+}
+
+// writeTypeID writes the type ID prefix for a top-level value.
+func writeTypeID(writer *Writer, value any) error {
+	id, err := typeIDOf(reflect.TypeOf(value))
+	if err != nil {
+		return err
+	}
+	writer.WriteInt32(int32(id))
+	return nil
+}
+
+// readTypeID reads the type ID prefix and returns the value to decode the rest
+// of the data into.
+//
+// If value points to an interface, a new value of the registered type is
+// allocated, and a pointer to it is stored in the interface and returned.
+// Otherwise the prefix must match the type value points to.
+func readTypeID(reader *Reader, value any) (any, error) {
+	id, err := reader.ReadInt32()
+	if err != nil {
+		return nil, err
+	}
+
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Interface {
+		registered, found := typeByTag.Load(uint16(id))
+		if !found || uint32(id) > 0xFFFF {
+			return nil, fmt.Errorf("type ID %d is not registered", uint32(id))
+		}
+		target := reflect.New(registered.(reflect.Type))
+		if !target.Type().AssignableTo(v.Elem().Type()) {
+			return nil, fmt.Errorf("type %s with type ID %d does not implement %s", target.Type(), uint32(id), v.Elem().Type())
+		}
+		v.Elem().Set(target)
+		return target.Interface(), nil
+	}
+
+	want, err := typeIDOf(reflect.TypeOf(value))
+	if err != nil {
+		return nil, err
+	}
+	if uint32(id) != want {
+		return nil, fmt.Errorf("data has type ID %d, but %T is registered as %d", uint32(id), value, want)
+	}
+	return value, nil
+}
@@ -0,0 +1,344 @@
+package memorypack_test
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestInterfaceValues tests interface values encoded through the type registry.
+func TestInterfaceValues(t *testing.T) {
+	t.Run("MapOfAny", func(t *testing.T) {
+		testRoundTrip(t, map[string]any{"n": 42, "s": "x", "b": true})
+		testRoundTrip(t, map[string]any{"nil": nil, "f": 1.5, "bytes": []byte{1, 2}})
+		testRoundTrip(t, map[string]any{"nested": map[string]any{"list": []any{int8(1), "two"}}})
+	})
+
+	t.Run("SliceOfAny", func(t *testing.T) {
+		testRoundTrip(t, []any{int16(1), int32(2), int64(3), float32(4), nil})
+	})
+
+	type Config struct {
+		Name   string
+		Values map[string]any
+	}
+
+	t.Run("StructField", func(t *testing.T) {
+		testRoundTrip(t, Config{Name: "cfg", Values: map[string]any{"retries": 3, "verbose": false}})
+	})
+
+	t.Run("UnregisteredType", func(t *testing.T) {
+		type unregistered struct{ A int }
+		value := map[string]any{"x": unregistered{A: 1}}
+		if _, err := memorypack.Serialize(&value); err == nil {
+			t.Error("Expected error for unregistered type in interface, got nil")
+		}
+	})
+}
+
+// TestRegisterType tests type registration rules.
+func TestRegisterType(t *testing.T) {
+	type Point struct{ X, Y int }
+
+	if err := memorypack.RegisterType(300, reflect.TypeFor[Point]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+	if err := memorypack.RegisterType(300, reflect.TypeFor[Point]()); err != nil {
+		t.Errorf("Re-registering the same type and tag should succeed, got: %v", err)
+	}
+	if err := memorypack.RegisterType(301, reflect.TypeFor[Point]()); err == nil {
+		t.Error("Expected error registering a type under a second tag, got nil")
+	}
+	if err := memorypack.RegisterType(300, reflect.TypeFor[string]()); err == nil {
+		t.Error("Expected error reusing a tag for another type, got nil")
+	}
+	if err := memorypack.RegisterType(0xFF00, reflect.TypeFor[Point]()); err == nil {
+		t.Error("Expected error for reserved tag, got nil")
+	}
+	if err := memorypack.RegisterType(302, reflect.TypeFor[any]()); err == nil {
+		t.Error("Expected error for interface type, got nil")
+	}
+
+	// Tag 300 needs the wide union header.
+	testRoundTrip(t, []any{Point{X: 1, Y: 2}, Point{X: 3}})
+}
+
+// textSource is an io.Reader stored by value.
+type textSource struct{ Text string }
+
+func (s textSource) Read(p []byte) (int, error) {
+	return copy(p, s.Text), io.EOF
+}
+
+// repeatSource is an io.Reader stored by pointer.
+type repeatSource struct {
+	Byte  byte
+	Count int32
+}
+
+func (s *repeatSource) Read(p []byte) (int, error) {
+	n := min(len(p), int(s.Count))
+	for i := range n {
+		p[i] = s.Byte
+	}
+	s.Count -= int32(n)
+	if s.Count == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// TestInterfaceSlices tests slices of a non-empty interface type.
+func TestInterfaceSlices(t *testing.T) {
+	if err := memorypack.RegisterType(310, reflect.TypeFor[textSource]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+	if err := memorypack.RegisterType(311, reflect.TypeFor[*repeatSource]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+
+	original := []io.Reader{textSource{Text: "hi"}, &repeatSource{Byte: 'z', Count: 3}, nil}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var result []io.Reader
+	if err = memorypack.Deserialize(data, &result); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !reflect.DeepEqual(original, result) {
+		t.Fatalf("Result mismatch: got %+v, want %+v", result, original)
+	}
+	for i, want := range []string{"hi", "zzz"} {
+		if got, err := io.ReadAll(result[i]); err != nil || string(got) != want {
+			t.Errorf("Element %d (%T) read %q, %v; want %q", i, result[i], got, err, want)
+		}
+	}
+
+	t.Run("NotImplemented", func(t *testing.T) {
+		values := []any{textSource{Text: "x"}}
+		data, err := memorypack.Serialize(&values)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		var writers []io.Writer
+		if err = memorypack.Deserialize(data, &writers); err == nil {
+			t.Error("Expected error decoding a reader into []io.Writer, got nil")
+		}
+	})
+}
+
+type loginMessage struct {
+	User string
+}
+
+type logoutMessage struct {
+	User   string
+	Reason int32
+}
+
+// TestTypeIDPrefix tests routing top-level messages by their peeked type ID.
+func TestTypeIDPrefix(t *testing.T) {
+	if err := memorypack.RegisterType(320, reflect.TypeFor[loginMessage]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+	if err := memorypack.RegisterType(321, reflect.TypeFor[logoutMessage]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+	opts := memorypack.Options{TypeIDPrefix: true}
+
+	data, err := memorypack.SerializeWithOptions(&logoutMessage{User: "kei", Reason: 2}, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	id, err := memorypack.PeekType(data)
+	if err != nil {
+		t.Fatalf("PeekType failed: %v", err)
+	}
+	var routed any
+	switch id {
+	case 320:
+		var msg loginMessage
+		err = memorypack.DeserializeWithOptions(data, &msg, opts)
+		routed = msg
+	case 321:
+		var msg logoutMessage
+		err = memorypack.DeserializeWithOptions(data, &msg, opts)
+		routed = msg
+	default:
+		t.Fatalf("Unexpected type ID %d", id)
+	}
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if want := (logoutMessage{User: "kei", Reason: 2}); routed != want {
+		t.Errorf("Got %+v, want %+v", routed, want)
+	}
+
+	var wrong loginMessage
+	if err = memorypack.DeserializeWithOptions(data, &wrong, opts); err == nil {
+		t.Error("Expected error decoding into a type with a different ID")
+	}
+	if _, err = memorypack.SerializeWithOptions(&refNode{}, opts); err == nil {
+		t.Error("Expected error for an unregistered type")
+	}
+	if _, err = memorypack.PeekType(data[:3]); err == nil {
+		t.Error("Expected error for a truncated prefix")
+	}
+}
+
+type taggedPerson struct {
+	Name string
+	Age  int32
+}
+
+// TestTypeIDPrefixAnyTarget tests decoding prefixed data into an interface.
+func TestTypeIDPrefixAnyTarget(t *testing.T) {
+	if err := memorypack.RegisterType(322, reflect.TypeFor[taggedPerson]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+	opts := memorypack.Options{TypeIDPrefix: true}
+
+	data, err := memorypack.SerializeWithOptions(&taggedPerson{Name: "Yuuka", Age: 16}, opts)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var result any
+	if err = memorypack.DeserializeWithOptions(data, &result, opts); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	person, ok := result.(*taggedPerson)
+	if !ok {
+		t.Fatalf("Expected *taggedPerson, got %T", result)
+	}
+	if person.Name != "Yuuka" || person.Age != 16 {
+		t.Errorf("Got %+v", person)
+	}
+
+	var reader io.Reader
+	if err = memorypack.DeserializeWithOptions(data, &reader, opts); err == nil {
+		t.Error("Expected error for an interface the type does not implement")
+	}
+}
+
+// Shape is embedded in labeledShape to test embedded interface fields.
+type Shape interface {
+	Area() float64
+}
+
+type squareShape struct {
+	Side float64
+}
+
+func (s squareShape) Area() float64 { return s.Side * s.Side }
+
+type labeledShape struct {
+	Label string
+	Shape
+}
+
+// TestEmbeddedInterface tests an embedded interface field holding a registered type.
+func TestEmbeddedInterface(t *testing.T) {
+	if err := memorypack.RegisterType(323, reflect.TypeFor[squareShape]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+
+	testRoundTrip(t, labeledShape{Label: "sq", Shape: squareShape{Side: 3}})
+	testRoundTrip(t, labeledShape{Label: "none"})
+
+	data, err := memorypack.Serialize(&labeledShape{Label: "sq", Shape: squareShape{Side: 2}})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var result labeledShape
+	if err = memorypack.Deserialize(data, &result); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if result.Area() != 4 {
+		t.Errorf("Expected the promoted Area method to return 4, got %v", result.Area())
+	}
+}
+
+// TestPointerToInterface tests fields that point to an interface value.
+func TestPointerToInterface(t *testing.T) {
+	if err := memorypack.RegisterType(323, reflect.TypeFor[squareShape]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+
+	type Canvas struct {
+		Main  *Shape
+		Empty *Shape
+	}
+	var main Shape = squareShape{Side: 5}
+	original := Canvas{Main: &main}
+
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var result Canvas
+	if err = memorypack.Deserialize(data, &result); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	if result.Empty != nil {
+		t.Errorf("Expected nil Empty, got %v", *result.Empty)
+	}
+	if result.Main == nil || *result.Main != main {
+		t.Fatalf("Expected Main to point to %v, got %v", main, result.Main)
+	}
+	if area := (*result.Main).Area(); area != 25 {
+		t.Errorf("Expected area 25, got %v", area)
+	}
+}
+
+// expr is an expression tree node stored through the type registry.
+type expr interface {
+	eval() int64
+}
+
+type litExpr struct {
+	Value int64
+}
+
+type addExpr struct {
+	Left, Right expr
+}
+
+func (l litExpr) eval() int64 { return l.Value }
+func (a addExpr) eval() int64 { return a.Left.eval() + a.Right.eval() }
+
+// TestRecursiveInterfaces tests unions nested through interface fields.
+func TestRecursiveInterfaces(t *testing.T) {
+	if err := memorypack.RegisterType(330, reflect.TypeFor[litExpr]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+	if err := memorypack.RegisterType(331, reflect.TypeFor[addExpr]()); err != nil {
+		t.Fatalf("RegisterType failed: %v", err)
+	}
+
+	var original expr = addExpr{
+		Left:  litExpr{1},
+		Right: addExpr{Left: litExpr{2}, Right: litExpr{3}},
+	}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var result expr
+	if err = memorypack.Deserialize(data, &result); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf("Got %+v, want %+v", result, original)
+	}
+	if got, want := result.eval(), original.eval(); got != want || got != 6 {
+		t.Errorf("Evaluated to %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,600 @@
+package memorypack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Schema describes the expected layout of serialized data for a Go type.
+//
+// It is built once from a sample value and used to check untrusted input before
+// decoding it. Every header and length is validated against the type, so decoding
+// a validated payload never allocates more than the payload size implies.
+type Schema struct {
+	typ  reflect.Type
+	root *schemaNode
+}
+
+// schemaNode is the expected layout of a single type within a Schema.
+type schemaNode struct {
+	typ     reflect.Type
+	elem    *schemaNode // slice, array and pointer elements, map values
+	key     *schemaNode // map keys
+	fields  []schemaField
+	minSize int  // smallest possible encoding in bytes
+	opaque  bool // checked by decoding into a scratch value
+}
+
+type schemaField struct {
+	info fieldInfo
+	node *schemaNode
+}
+
+// SchemaError reports where serialized data diverges from a Schema.
+type SchemaError struct {
+	Path   string // location of the offending value, e.g. "$.Tags[2]"
+	Offset int    // byte offset in the data
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("schema violation at %s (offset %d): %s", e.Path, e.Offset, e.Reason)
+}
+
+// NewSchema builds a Schema for the type of sample. A pointer sample describes
+// the value it points to, matching what Serialize writes for it.
+func NewSchema(sample any) (*Schema, error) {
+	t := reflect.TypeOf(sample)
+	if t == nil {
+		return nil, fmt.Errorf("cannot build a schema for nil")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	root, err := buildSchemaNode(t, map[reflect.Type]*schemaNode{})
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{typ: t, root: root}, nil
+}
+
+// buildSchemaNode builds the node for t. Nodes are shared through seen so that
+// recursive types terminate.
+func buildSchemaNode(t reflect.Type, seen map[reflect.Type]*schemaNode) (*schemaNode, error) {
+	if node, found := seen[t]; found {
+		return node, nil
+	}
+	node := &schemaNode{typ: t, minSize: 1}
+	seen[t] = node
+
+	if isKnownType(t) || isFormatterType(t) || isEnumType(t) || isOptionalType(t) {
+		node.opaque = true
+		return node, nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+	case reflect.Int16, reflect.Uint16:
+		node.minSize = 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		node.minSize = 4
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		node.minSize = 8
+	case reflect.String:
+		node.minSize = 4
+	case reflect.Slice, reflect.Array:
+		node.minSize = 4
+		node.elem, err = buildSchemaNode(t.Elem(), seen)
+	case reflect.Map:
+		node.minSize = 4
+		if node.key, err = buildSchemaNode(t.Key(), seen); err == nil {
+			node.elem, err = buildSchemaNode(t.Elem(), seen)
+		}
+	case reflect.Struct:
+		var filtered map[reflect.Type]formatterData
+		for _, field := range filterFormatterData(t, &Options{}, &filtered).fields {
+			child, err := buildSchemaNode(t.Field(field.index).Type, seen)
+			if err != nil {
+				return nil, err
+			}
+			node.fields = append(node.fields, schemaField{info: field, node: child})
+			node.minSize += child.minSize
+		}
+	case reflect.Ptr:
+		node.elem, err = buildSchemaNode(t.Elem(), seen)
+	case reflect.Interface, reflect.Func:
+		node.opaque = true
+	default:
+		return nil, fmt.Errorf("%w in schema: %s", ErrUnsupportedType, t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Validate checks that data is a well-formed encoding of the schema's type
+// and that no bytes follow it.
+func (s *Schema) Validate(data []byte) error {
+	return s.validate(NewReader(data))
+}
+
+// validate walks the reader against the schema without decoding values.
+func (s *Schema) validate(reader *Reader) error {
+	sv := &schemaValidator{reader: reader}
+	if reader.options.TrackReferences {
+		// Reference 0 is the top-level pointer.
+		sv.refs = append(sv.refs, reflect.PointerTo(s.typ))
+	}
+	if err := sv.value(s.root); err != nil {
+		return err
+	}
+	if reader.src == nil && reader.pos != len(reader.buffer) {
+		return sv.fail("%d trailing bytes after value", len(reader.buffer)-reader.pos)
+	}
+	return nil
+}
+
+// schemas caches the schemas built by Validate.
+var schemas sync.Map // map[reflect.Type]*Schema
+
+// Validate checks that data is a well-formed encoding of the type of sample,
+// without decoding it. See Schema.Validate.
+func Validate(data []byte, sample any) error {
+	t := reflect.TypeOf(sample)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if schema, ok := schemas.Load(t); ok {
+		return schema.(*Schema).Validate(data)
+	}
+
+	schema, err := NewSchema(sample)
+	if err != nil {
+		return err
+	}
+	schemas.Store(t, schema)
+	return schema.Validate(data)
+}
+
+// DeserializeWithSchema validates data against schema and then deserializes it.
+//
+// value must be a pointer to the type the schema was built from. Nothing is
+// decoded unless the whole payload conforms to the schema.
+func DeserializeWithSchema[T any](data []byte, value T, schema *Schema) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.Type().Elem() != schema.typ {
+		return fmt.Errorf("schema for %s cannot decode into %T", schema.typ, value)
+	}
+	if err := schema.Validate(data); err != nil {
+		return err
+	}
+	return Deserialize(data, value)
+}
+
+// pathSegment is one step of the path to the value being validated.
+type pathSegment struct {
+	field string
+	index int
+	key   bool
+}
+
+// schemaValidator walks serialized data against a schema.
+type schemaValidator struct {
+	reader *Reader
+	path   []pathSegment
+	refs   []reflect.Type
+}
+
+// fail returns a SchemaError for the current position.
+func (sv *schemaValidator) fail(format string, args ...any) error {
+	var path strings.Builder
+	path.WriteString("$")
+	for _, seg := range sv.path {
+		switch {
+		case seg.field != "":
+			path.WriteString("." + seg.field)
+		case seg.key:
+			path.WriteString("[" + strconv.Itoa(seg.index) + "].key")
+		default:
+			path.WriteString("[" + strconv.Itoa(seg.index) + "]")
+		}
+	}
+	return &SchemaError{Path: path.String(), Offset: sv.reader.pos, Reason: fmt.Sprintf(format, args...)}
+}
+
+// need fails unless n more bytes are available.
+func (sv *schemaValidator) need(n int, what string) error {
+	if !sv.reader.ensure(n) {
+		return sv.fail("truncated %s: need %d bytes, %d available", what, n, len(sv.reader.buffer)-sv.reader.pos)
+	}
+	return nil
+}
+
+// skip consumes n bytes after checking they are available.
+func (sv *schemaValidator) skip(n int, what string) error {
+	if err := sv.need(n, what); err != nil {
+		return err
+	}
+	sv.reader.pos += n
+	return nil
+}
+
+// int32 reads a little-endian int32 header.
+func (sv *schemaValidator) int32(what string) (int32, error) {
+	if err := sv.need(4, what); err != nil {
+		return 0, err
+	}
+	v := int32(binary.LittleEndian.Uint32(sv.reader.buffer[sv.reader.pos:]))
+	sv.reader.pos += 4
+	return v, nil
+}
+
+// collectionLength checks a collection header against the remaining data.
+// It returns -1 for a null collection.
+func (sv *schemaValidator) collectionLength(elemSize int) (int, error) {
+	length, err := sv.int32("collection header")
+	if err != nil {
+		return 0, err
+	}
+	if length == NullCollection {
+		return -1, nil
+	}
+	if length < 0 {
+		return 0, sv.fail("invalid collection length %d", length)
+	}
+	if remaining := len(sv.reader.buffer) - sv.reader.pos; sv.reader.src == nil && int(length) > remaining/elemSize {
+		return 0, sv.fail("collection length %d exceeds the %d bytes remaining", length, remaining)
+	}
+	return int(length), nil
+}
+
+// value validates one value against node.
+func (sv *schemaValidator) value(node *schemaNode) error {
+	r := sv.reader
+	_, tagged := primitiveTag(node.typ.Kind())
+	if node.opaque || tagged && r.options.TaggedPrimitives && (r.options.LenientNumeric || r.options.ReinterpretSignedness) {
+		if err := readValue(r, reflect.New(node.typ).Elem()); err != nil {
+			return sv.fail("%v", err)
+		}
+		return nil
+	}
+
+	kind := node.typ.Kind()
+	if r.options.TaggedPrimitives {
+		if tag, ok := primitiveTag(kind); ok {
+			if err := sv.need(1, "primitive tag"); err != nil {
+				return err
+			}
+			if err := readPrimitiveTag(r, tag); err != nil {
+				return sv.fail("%v", err)
+			}
+		}
+	}
+
+	switch kind {
+	case reflect.Bool:
+		if err := sv.need(1, "bool"); err != nil {
+			return err
+		}
+		if b := r.buffer[r.pos]; b > 1 {
+			return sv.fail("invalid bool byte 0x%02x", b)
+		}
+		r.pos++
+		return nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Float32, reflect.Float64:
+		return sv.skip(node.minSize, kind.String())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		if err := sv.need(node.minSize, kind.String()); err != nil {
+			return err
+		}
+		var buf [8]byte
+		copy(buf[:], r.buffer[r.pos:r.pos+node.minSize])
+		if err := checkFlagMask(node.typ, binary.LittleEndian.Uint64(buf[:])); err != nil {
+			return sv.fail("%v", err)
+		}
+		r.pos += node.minSize
+		return nil
+	case reflect.String:
+		return sv.string()
+	case reflect.Slice:
+		if r.options.ShareSlices {
+			return sv.reference(node, func() error { return sv.slice(node) })
+		}
+		return sv.slice(node)
+	case reflect.Array:
+		length, err := sv.collectionLength(sv.minSize(node.elem))
+		if err != nil {
+			return err
+		}
+		if length != node.typ.Len() && r.options.StrictArrayLength {
+			return sv.fail("array length %d does not match %s", length, node.typ)
+		}
+		return sv.elements(node.elem, length)
+	case reflect.Map:
+		return sv.mapEntries(node)
+	case reflect.Struct:
+		return sv.structFields(node)
+	case reflect.Ptr:
+		return sv.pointer(node)
+	default:
+		return sv.fail("unsupported kind %s", kind)
+	}
+}
+
+// minSize returns the smallest encoding of node under the reader's options.
+// Compact strings and shared slices can take a single byte, and structs
+// may contain either.
+func (sv *schemaValidator) minSize(node *schemaNode) int {
+	if sv.reader.options.CompactStrings || sv.reader.options.ShareSlices {
+		return 1
+	}
+	return node.minSize
+}
+
+// string validates a string header and its bytes.
+func (sv *schemaValidator) string() error {
+	if sv.reader.options.CompactStrings {
+		if err := sv.need(1, "string header"); err != nil {
+			return err
+		}
+		header := sv.reader.buffer[sv.reader.pos]
+		sv.reader.pos++
+		switch {
+		case header < shortStringLimit:
+			return sv.skip(int(header), "string")
+		case header != longString:
+			return sv.fail("invalid compact string header 0x%02x", header)
+		}
+	}
+
+	header, err := sv.int32("string header")
+	if err != nil {
+		return err
+	}
+	if header == 0 || header == NullCollection {
+		return nil
+	}
+	if header > 0 {
+		return sv.fail("invalid string header %d", header)
+	}
+
+	byteCount := ^header
+	charCount, err := sv.int32("string length")
+	if err != nil {
+		return err
+	}
+	if charCount < 0 || charCount > byteCount {
+		return sv.fail("string length %d is inconsistent with %d bytes", charCount, byteCount)
+	}
+	return sv.skip(int(byteCount), "string")
+}
+
+// slice validates a slice, including the special []byte and packed []bool forms.
+func (sv *schemaValidator) slice(node *schemaNode) error {
+	switch node.elem.typ.Kind() {
+	case reflect.Uint8:
+		if isEnumType(node.elem.typ) {
+			break
+		}
+		length, err := sv.collectionLength(1)
+		if err != nil || length < 0 {
+			return err
+		}
+		return sv.skip(length, "byte slice")
+	case reflect.Bool:
+		header, err := sv.int32("collection header")
+		if err != nil {
+			return err
+		}
+		if header < NullCollection {
+			return sv.skip((int(packedBoolsHeader(0)-header)+7)/8, "packed bools")
+		}
+		sv.reader.pos -= 4
+	case reflect.Int, reflect.Int64:
+		if !isDeltaSlice(node.typ, &sv.reader.options) {
+			break
+		}
+		length, err := sv.collectionLength(1)
+		if err != nil || length < 0 {
+			return err
+		}
+		for range length {
+			if _, err = sv.reader.ReadVarint(); err != nil {
+				return sv.fail("%v", err)
+			}
+		}
+		return nil
+	}
+
+	length, err := sv.collectionLength(sv.minSize(node.elem))
+	if err != nil || length < 0 {
+		return err
+	}
+	return sv.elements(node.elem, length)
+}
+
+// elements validates length consecutive values of node.
+func (sv *schemaValidator) elements(node *schemaNode, length int) error {
+	sv.path = append(sv.path, pathSegment{})
+	for i := range length {
+		sv.path[len(sv.path)-1].index = i
+		if err := sv.value(node); err != nil {
+			return err
+		}
+	}
+	sv.path = sv.path[:len(sv.path)-1]
+	return nil
+}
+
+// mapEntries validates the key/value pairs of a map.
+func (sv *schemaValidator) mapEntries(node *schemaNode) error {
+	length, err := sv.collectionLength(sv.minSize(node.key) + sv.minSize(node.elem))
+	if err != nil || length < 0 {
+		return err
+	}
+
+	if isDenseMap(node.typ, &sv.reader.options) {
+		bitmap, err := readDenseMapBitmap(sv.reader, length)
+		if err != nil {
+			return sv.fail("%v", err)
+		}
+		if bitmap != nil {
+			return sv.denseMapValues(node, bitmap)
+		}
+	}
+
+	compactKeys := sv.reader.options.CompactMapKeys && isSignedInt(node.key.typ.Kind())
+	sv.path = append(sv.path, pathSegment{})
+	for i := range length {
+		// Re-index after nested calls, which may reallocate sv.path.
+		sv.path[len(sv.path)-1] = pathSegment{index: i, key: true}
+		if compactKeys {
+			key, err := sv.reader.ReadVarint()
+			if err != nil {
+				return sv.fail("%v", err)
+			}
+			if reflect.New(node.key.typ).Elem().OverflowInt(key) {
+				return sv.fail("map key %d overflows %s", key, node.key.typ)
+			}
+		} else if err = sv.value(node.key); err != nil {
+			return err
+		}
+
+		sv.path[len(sv.path)-1].key = false
+		if err = sv.value(node.elem); err != nil {
+			return err
+		}
+	}
+	sv.path = sv.path[:len(sv.path)-1]
+	return nil
+}
+
+// denseMapValues validates the values of a dense map with the given key
+// bitmap.
+func (sv *schemaValidator) denseMapValues(node *schemaNode, bitmap []byte) error {
+	key := reflect.New(node.key.typ).Elem()
+	sv.path = append(sv.path, pathSegment{})
+	for i := range len(bitmap) * 8 {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if isSignedInt(key.Kind()) && key.OverflowInt(int64(i)) || isUnsignedInt(key.Kind()) && key.OverflowUint(uint64(i)) {
+			return sv.fail("map key %d overflows %s", i, node.key.typ)
+		}
+		sv.path[len(sv.path)-1].index = i
+		if err := sv.value(node.elem); err != nil {
+			return err
+		}
+	}
+	sv.path = sv.path[:len(sv.path)-1]
+	return nil
+}
+
+// structFields validates an object header and the fields that follow it.
+func (sv *schemaValidator) structFields(node *schemaNode) error {
+	if isZeroMarked(&sv.reader.options) && sv.reader.pos < len(sv.reader.buffer) && sv.reader.buffer[sv.reader.pos] == zeroStruct {
+		sv.reader.pos++
+		return nil
+	}
+	if sv.reader.options.KeyedFields || sv.reader.options.FieldPresence {
+		// Keyed structs carry their own field lengths, and presence bitmaps
+		// vary the fields that follow; check them by decoding.
+		if err := readValue(sv.reader, reflect.New(node.typ).Elem()); err != nil {
+			return sv.fail("%v", err)
+		}
+		return nil
+	}
+	if err := sv.need(1, "object header"); err != nil {
+		return err
+	}
+	header := sv.reader.buffer[sv.reader.pos]
+	sv.reader.pos++
+	if header == NullObject {
+		return nil
+	}
+	if header > WideTag-1 {
+		return sv.fail("invalid object header %d", header)
+	}
+	if int(header) > len(node.fields) || int(header) < len(node.fields) && !sv.reader.options.AllowMissingFields {
+		return sv.fail("expected %d fields, stream has %d", len(node.fields), header)
+	}
+
+	for _, field := range node.fields[:header] {
+		sv.path = append(sv.path, pathSegment{field: field.info.name})
+		var err error
+		if field.info.transformed() {
+			if err = readField(sv.reader, field.info, reflect.New(field.node.typ).Elem()); err != nil {
+				err = sv.fail("%v", err)
+			}
+		} else {
+			err = sv.value(field.node)
+		}
+		if err != nil {
+			return err
+		}
+		sv.path = sv.path[:len(sv.path)-1]
+	}
+	return nil
+}
+
+// pointer validates a possibly null pointer.
+func (sv *schemaValidator) pointer(node *schemaNode) error {
+	if err := sv.need(1, "pointer header"); err != nil {
+		return err
+	}
+	header := sv.reader.buffer[sv.reader.pos]
+
+	if !sv.reader.options.TrackReferences {
+		if header == NullObject {
+			sv.reader.pos++
+			return nil
+		}
+		if hasPresenceMarker(node.elem.typ, &sv.reader.options) {
+			if header != pointerPresent {
+				return sv.fail("invalid pointer marker %d", header)
+			}
+			sv.reader.pos++
+		}
+		return sv.value(node.elem)
+	}
+	return sv.reference(node, func() error { return sv.value(node.elem) })
+}
+
+// reference validates a tracked value header, calling body to validate the
+// value on its first occurrence.
+func (sv *schemaValidator) reference(node *schemaNode, body func() error) error {
+	if err := sv.need(1, "reference header"); err != nil {
+		return err
+	}
+	header := sv.reader.buffer[sv.reader.pos]
+	sv.reader.pos++
+	switch header {
+	case NullObject:
+		return nil
+	case ReferenceID:
+		id, err := sv.reader.ReadUvarint()
+		if err != nil {
+			return sv.fail("%v", err)
+		}
+		if id >= uint64(len(sv.refs)) {
+			return sv.fail("invalid reference id %d", id)
+		}
+		if sv.refs[id] != node.typ {
+			return sv.fail("reference %d has type %s, expected %s", id, sv.refs[id], node.typ)
+		}
+		return nil
+	case ReferenceNew:
+		sv.refs = append(sv.refs, node.typ)
+		return body()
+	default:
+		return sv.fail("invalid reference header %d", header)
+	}
+}
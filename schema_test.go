@@ -0,0 +1,215 @@
+package memorypack_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type schemaItem struct {
+	Name  string
+	Tags  []string
+	Score float64
+}
+
+type schemaOrder struct {
+	ID     int32
+	Active bool
+	Items  []schemaItem
+	Counts map[string]int16
+	Next   *schemaOrder
+}
+
+// TestSchema tests validating payloads against a schema before decoding.
+func TestSchema(t *testing.T) {
+	schema, err := memorypack.NewSchema(schemaOrder{})
+	if err != nil {
+		t.Fatalf("NewSchema failed: %v", err)
+	}
+
+	original := schemaOrder{
+		ID:     7,
+		Active: true,
+		Items:  []schemaItem{{Name: "a", Tags: []string{"x", "y"}, Score: 1.5}},
+		Counts: map[string]int16{"k": 3},
+		Next:   &schemaOrder{ID: 8},
+	}
+	valid, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		var result schemaOrder
+		if err := memorypack.DeserializeWithSchema(valid, &result, schema); err != nil {
+			t.Fatalf("DeserializeWithSchema failed: %v", err)
+		}
+		if result.ID != 7 || result.Next == nil || result.Next.ID != 8 || result.Items[0].Tags[1] != "y" {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	// Offsets into valid: header(1) ID(4) Active(1) Items header(4) item header(1) Name...
+	const (
+		activeOffset = 5
+		itemsOffset  = 6
+		nameOffset   = 11
+	)
+
+	hostile := []struct {
+		name   string
+		mutate func([]byte) []byte
+		path   string
+		reason string
+	}{
+		{
+			name:   "FieldCount",
+			mutate: func(b []byte) []byte { b[0] = 4; return b },
+			path:   "$",
+			reason: "expected 5 fields, stream has 4",
+		},
+		{
+			name:   "InvalidBool",
+			mutate: func(b []byte) []byte { b[activeOffset] = 5; return b },
+			path:   "$.Active",
+			reason: "invalid bool byte 0x05",
+		},
+		{
+			name: "HugeLength",
+			mutate: func(b []byte) []byte {
+				binary.LittleEndian.PutUint32(b[itemsOffset:], 1<<30)
+				return b
+			},
+			path:   "$.Items",
+			reason: "collection length 1073741824 exceeds the",
+		},
+		{
+			name: "NegativeLength",
+			mutate: func(b []byte) []byte {
+				binary.LittleEndian.PutUint32(b[itemsOffset:], 0xFFFFFFF0)
+				return b
+			},
+			path:   "$.Items",
+			reason: "invalid collection length -16",
+		},
+		{
+			name: "WrongKind",
+			mutate: func(b []byte) []byte {
+				binary.LittleEndian.PutUint32(b[nameOffset:], 3)
+				return b
+			},
+			path:   "$.Items[0].Name",
+			reason: "invalid string header 3",
+		},
+		{
+			name:   "Truncated",
+			mutate: func(b []byte) []byte { return b[:len(b)-3] },
+			path:   "$.Next.Counts",
+			reason: "truncated collection header",
+		},
+		{
+			name:   "TrailingBytes",
+			mutate: func(b []byte) []byte { return append(b, 0) },
+			path:   "$",
+			reason: "1 trailing bytes after value",
+		},
+	}
+
+	for _, tc := range hostile {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.mutate(append([]byte(nil), valid...))
+
+			var result schemaOrder
+			err := memorypack.DeserializeWithSchema(data, &result, schema)
+
+			var schemaErr *memorypack.SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("Expected SchemaError, got %v", err)
+			}
+			if schemaErr.Path != tc.path || !strings.HasPrefix(schemaErr.Reason, tc.reason) {
+				t.Errorf("Got %s: %s, want %s: %s", schemaErr.Path, schemaErr.Reason, tc.path, tc.reason)
+			}
+			if result.ID != 0 {
+				t.Errorf("Expected nothing to be decoded, got %+v", result)
+			}
+		})
+	}
+
+	t.Run("StructKeyPath", func(t *testing.T) {
+		// Validating a struct key grows the path, which must not leave the
+		// map entry reported as a key once the value is reached.
+		type inner struct{ B int32 }
+		type key struct{ A inner }
+		data, err := memorypack.Serialize(map[key]bool{{A: inner{B: 1}}: true})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		data[len(data)-1] = 2
+
+		err = memorypack.Validate(data, map[key]bool{})
+		var schemaErr *memorypack.SchemaError
+		if !errors.As(err, &schemaErr) || schemaErr.Path != "$[0]" {
+			t.Errorf("Expected an error at $[0], got %v", err)
+		}
+	})
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		var result schemaItem
+		if err := memorypack.DeserializeWithSchema(valid, &result, schema); err == nil {
+			t.Error("Expected error decoding into a different type, got nil")
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if _, err := memorypack.NewSchema(make(chan int)); err == nil {
+			t.Error("Expected error for unsupported type, got nil")
+		}
+	})
+}
+
+// TestValidate tests checking payloads against the type of a sample value.
+func TestValidate(t *testing.T) {
+	original := schemaOrder{
+		ID:    1,
+		Items: []schemaItem{{Name: "a", Tags: []string{"x"}}, {Name: "b"}},
+		Next:  &schemaOrder{ID: 2, Counts: map[string]int16{"n": 1}},
+	}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		if err := memorypack.Validate(data, &schemaOrder{}); err != nil {
+			t.Errorf("Validate failed: %v", err)
+		}
+		if err := memorypack.Validate(data, schemaOrder{}); err != nil {
+			t.Errorf("Validate with a value sample failed: %v", err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		for _, n := range []int{0, 1, 10, len(data) - 1} {
+			var schemaErr *memorypack.SchemaError
+			if err := memorypack.Validate(data[:n], &schemaOrder{}); !errors.As(err, &schemaErr) {
+				t.Errorf("Expected SchemaError for %d of %d bytes, got %v", n, len(data), err)
+			}
+		}
+	})
+
+	t.Run("TrailingGarbage", func(t *testing.T) {
+		err := memorypack.Validate(append(data, 0xAB, 0xCD), &schemaOrder{})
+		if err == nil || !strings.Contains(err.Error(), "2 trailing bytes") {
+			t.Errorf("Expected trailing bytes error, got %v", err)
+		}
+	})
+
+	t.Run("UnsupportedSample", func(t *testing.T) {
+		if err := memorypack.Validate(data, nil); err == nil {
+			t.Error("Expected error for a nil sample")
+		}
+	})
+}
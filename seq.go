@@ -0,0 +1,88 @@
+package memorypack
+
+import (
+	"iter"
+	"reflect"
+)
+
+// DeserializeMapSeq returns an iterator over the entries of a serialized map
+// without building the map.
+//
+// The data is validated against the map type up front, so iteration itself
+// cannot fail. Each entry is decoded only when the iterator reaches it, and the
+// iterator can be ranged over more than once.
+func DeserializeMapSeq[K comparable, V any](data []byte) (iter.Seq2[K, V], error) {
+	schema, err := NewSchema(map[K]V{})
+	if err != nil {
+		return nil, err
+	}
+	if err = schema.Validate(data); err != nil {
+		return nil, err
+	}
+
+	return func(yield func(K, V) bool) {
+		reader := NewReader(data)
+		length, isNull, err := reader.ReadCollectionHeader()
+		if err != nil || isNull {
+			return
+		}
+
+		for range length {
+			var key K
+			var value V
+			if readMapKey(reader, reflect.ValueOf(&key).Elem()) != nil ||
+				readValue(reader, reflect.ValueOf(&value).Elem()) != nil {
+				return
+			}
+			if !yield(key, value) {
+				return
+			}
+		}
+	}, nil
+}
+
+// DeserializeSliceUntil decodes the elements of a serialized slice one at a
+// time until stop returns true, and returns the elements decoded so far,
+// including the one stop accepted. The rest of the data is not decoded, which
+// makes it cheap to scan sorted data for a value near its start.
+func DeserializeSliceUntil[T any](data []byte, stop func(T) bool) ([]T, error) {
+	reader := NewReader(data)
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil || isNull {
+		return nil, err
+	}
+
+	result := make([]T, 0, min(length, 64))
+	for i := range length {
+		var elem T
+		if err = readValue(reader, reflect.ValueOf(&elem).Elem()); err != nil {
+			return result, withIndex(err, i)
+		}
+		result = append(result, elem)
+		if stop(elem) {
+			break
+		}
+	}
+	return result, nil
+}
+
+// DeserializeToChannel decodes the elements of a serialized slice one at a
+// time and sends each to ch, so that a consumer can process them while the
+// rest are decoded. ch is closed when decoding ends, whether or not it failed.
+func DeserializeToChannel[T any](data []byte, ch chan<- T) error {
+	defer close(ch)
+	reader := NewReader(data)
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil || isNull {
+		return err
+	}
+
+	for i := range length {
+		var elem T
+		if err = readValue(reader, reflect.ValueOf(&elem).Elem()); err != nil {
+			return withIndex(err, i)
+		}
+		ch <- elem
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package memorypack_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestDeserializeMapSeq tests iterating a serialized map without materializing it.
+func TestDeserializeMapSeq(t *testing.T) {
+	original := make(map[int32]int64, 10000)
+	var want int64
+	for i := range int32(10000) {
+		original[i] = int64(i) * 3
+		want += int64(i) * 3
+	}
+
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	seq, err := memorypack.DeserializeMapSeq[int32, int64](data)
+	if err != nil {
+		t.Fatalf("DeserializeMapSeq failed: %v", err)
+	}
+
+	var sum int64
+	var count int
+	for key, value := range seq {
+		if value != int64(key)*3 {
+			t.Errorf("Entry %d: got %d", key, value)
+		}
+		sum += value
+		count++
+	}
+	if count != len(original) || sum != want {
+		t.Errorf("Got %d entries summing to %d, want %d summing to %d", count, sum, len(original), want)
+	}
+
+	t.Run("EarlyStop", func(t *testing.T) {
+		var seen int
+		for range seq {
+			if seen++; seen == 5 {
+				break
+			}
+		}
+		if seen != 5 {
+			t.Errorf("Expected iteration to stop after 5 entries, got %d", seen)
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		if _, err := memorypack.DeserializeMapSeq[int32, int64](data[:len(data)-1]); err == nil {
+			t.Error("Expected error for truncated data, got nil")
+		}
+	})
+}
+
+// TestDeserializeSliceUntil tests stopping partway through a serialized slice.
+func TestDeserializeSliceUntil(t *testing.T) {
+	type Event struct {
+		At   int64
+		Name string
+	}
+	events := make([]Event, 100_000)
+	for i := range events {
+		events[i] = Event{At: int64(i) * 10, Name: "event"}
+	}
+	data, err := memorypack.Serialize(&events)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	atOrAfter := func(at int64) func(Event) bool {
+		return func(e Event) bool { return e.At >= at }
+	}
+
+	// The tail is never decoded, so it may as well be missing.
+	result, err := memorypack.DeserializeSliceUntil(data[:len(data)/2], atOrAfter(995))
+	if err != nil {
+		t.Fatalf("DeserializeSliceUntil failed: %v", err)
+	}
+	if len(result) != 101 || result[100].At != 1000 {
+		t.Errorf("Expected 101 events ending at 1000, got %d ending at %+v", len(result), result[len(result)-1])
+	}
+
+	all, err := memorypack.DeserializeSliceUntil(data, atOrAfter(math.MaxInt64))
+	if err != nil || len(all) != len(events) {
+		t.Errorf("Expected all %d events, got %d, err: %v", len(events), len(all), err)
+	}
+
+	if _, err = memorypack.DeserializeSliceUntil(data[:len(data)/2], atOrAfter(math.MaxInt64)); err == nil {
+		t.Error("Expected error for truncated data when scanning to the end")
+	}
+}
+
+// TestDeserializeToChannel tests sending decoded slice elements to a channel.
+func TestDeserializeToChannel(t *testing.T) {
+	original := []string{"a", "b", "", "d"}
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	ch := make(chan string, len(original))
+	if err = memorypack.DeserializeToChannel(data, ch); err != nil {
+		t.Fatalf("DeserializeToChannel failed: %v", err)
+	}
+	var result []string
+	for s := range ch {
+		result = append(result, s)
+	}
+	if !slices.Equal(result, original) {
+		t.Errorf("Got %q, want %q", result, original)
+	}
+
+	// A truncated stream still closes the channel after the elements before it.
+	ch = make(chan string, len(original))
+	if err = memorypack.DeserializeToChannel(data[:len(data)-1], ch); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+	if n := len(ch); n != 3 {
+		t.Errorf("Expected 3 elements before the error, got %d", n)
+	}
+	for range ch {
+	}
+}
@@ -0,0 +1,21 @@
+package memorypack
+
+import (
+	"io"
+)
+
+// NewStreamReader creates a new MemoryPack reader that pulls data from src on demand.
+//
+// The reader only requests as many bytes from src as decoding needs, so any
+// io.Reader works, including a *bufio.Reader shared with other consumers.
+func NewStreamReader(src io.Reader) *Reader {
+	return &Reader{src: src}
+}
+
+// DeserializeFrom deserializes a value read from an io.Reader.
+//
+// value must be a pointer to a value. Bytes following the encoded value are
+// left unread in r.
+func DeserializeFrom[T any](r io.Reader, value T) error {
+	return decode(NewStreamReader(r), value)
+}
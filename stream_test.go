@@ -0,0 +1,86 @@
+package memorypack_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+// TestDeserializeFrom tests decoding from a streaming source.
+func TestDeserializeFrom(t *testing.T) {
+	type Address struct {
+		City string
+		Zip  *int32
+	}
+
+	type Person struct {
+		Name    string
+		Address *Address
+		Manager *Address
+		Scores  []int
+	}
+
+	zip := int32(12345)
+	original := Person{
+		Name:    "Alice",
+		Address: &Address{City: "Springfield", Zip: &zip},
+		Manager: nil,
+		Scores:  []int{1, 2, 3},
+	}
+
+	data, err := memorypack.Serialize(&original)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	t.Run("OneByteReader", func(t *testing.T) {
+		// Every Peek of a pointer header has to pull its byte from the source.
+		var result Person
+		if err = memorypack.DeserializeFrom(iotest.OneByteReader(bytes.NewReader(data)), &result); err != nil {
+			t.Fatalf("DeserializeFrom failed: %v", err)
+		}
+		if !reflect.DeepEqual(result, original) {
+			t.Errorf("Result mismatch: got %+v, want %+v", result, original)
+		}
+	})
+
+	t.Run("BufioReaderWithTrailingData", func(t *testing.T) {
+		src := bufio.NewReaderSize(bytes.NewReader(append(data, 0xAB)), 16)
+
+		var result Person
+		if err = memorypack.DeserializeFrom(src, &result); err != nil {
+			t.Fatalf("DeserializeFrom failed: %v", err)
+		}
+		if !reflect.DeepEqual(result, original) {
+			t.Errorf("Result mismatch: got %+v, want %+v", result, original)
+		}
+
+		// The byte after the message must still be available to the caller.
+		if b, err := src.ReadByte(); err != nil || b != 0xAB {
+			t.Errorf("Expected trailing byte 0xAB, got %x, err: %v", b, err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		var result Person
+		truncated := iotest.OneByteReader(bytes.NewReader(data[:len(data)-3]))
+		if err = memorypack.DeserializeFrom(truncated, &result); err == nil {
+			t.Error("Expected error when stream ends early, got nil")
+		}
+	})
+
+	t.Run("HugeLength", func(t *testing.T) {
+		// The length must be checked against the stream before allocating.
+		var result []int64
+		err := memorypack.DeserializeFrom(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0x7F, 1, 2}), &result)
+		if !errors.Is(err, memorypack.ErrTruncated) || !strings.Contains(err.Error(), "collection length") {
+			t.Errorf("Expected the length to be rejected, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package memorypack
+
+import "fmt"
+
+// Header bytes of strings written with Options.CompactStrings. A header below
+// shortStringLimit is the byte length of the string that follows; longString
+// is followed by the standard string encoding.
+const (
+	shortStringLimit = 0x80
+	longString       = 0x80
+)
+
+// writeShortString writes v in the compact form if it is short enough and
+// reports whether it did. Otherwise it writes the long string marker, and the
+// caller must follow it with the standard encoding.
+func (w *Writer) writeShortString(v string) bool {
+	if len(v) >= shortStringLimit {
+		w.WriteByte(longString)
+		return false
+	}
+	w.ensureCapacity(1 + len(v))
+	w.buffer[w.pos] = byte(len(v))
+	copy(w.buffer[w.pos+1:], v)
+	w.pos += 1 + len(v)
+	return true
+}
+
+// readShortString reads a string header written by writeShortString. It
+// reports whether the string was short; if not, the standard encoding follows.
+func (r *Reader) readShortString() (string, bool, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return "", false, err
+	}
+	if header == longString {
+		return "", false, nil
+	}
+	if header > shortStringLimit {
+		return "", false, fmt.Errorf("%w: compact string header 0x%02x", ErrInvalidHeader, header)
+	}
+
+	n := int(header)
+	if limit := r.options.MaxStringLength; limit > 0 && n > limit {
+		return "", false, fmt.Errorf("string length %d exceeds the limit of %d bytes", n, limit)
+	}
+	if !r.ensure(n) {
+		return "", false, fmt.Errorf("read error: requested %d bytes for string but only %d bytes available: %w",
+			n, len(r.buffer)-r.pos, ErrTruncated)
+	}
+	if err = r.allocate(n, 1); err != nil {
+		return "", false, err
+	}
+	v := string(r.buffer[r.pos : r.pos+n])
+	r.pos += n
+	return v, true, nil
+}
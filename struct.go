@@ -2,31 +2,142 @@ package memorypack
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 )
 
-var formatterCache sync.Map // map[reflect.Type]formatterData
+// defaultTagName is the struct tag key read when Options.TagName is empty.
+const defaultTagName = "memorypack"
+
+var formatterCache sync.Map // map[formatterKey]formatterData
+
+// formatterKey identifies formatter data by type and by the options that
+// change how the type's fields are read.
+type formatterKey struct {
+	typ     reflect.Type
+	tagName string
+	order   FieldOrder
+}
 
 type formatterData struct {
 	fields []fieldInfo
+
+	// podSize is the encoded size of the fields when the struct can be copied
+	// to and from the wire directly, or 0.
+	podSize  int
+	podBools []int
+
+	// unknown is the index of the field tagged ",unknown" that collects
+	// unmatched keyed fields, or nil.
+	unknown []int
 }
 
 type fieldInfo struct {
-	index int
-	kind  reflect.Kind
-	name  string
-	order int
+	index    int
+	kind     reflect.Kind
+	name     string
+	order    int
+	compress bool
+	encrypt  bool
 }
 
+// transformed reports whether the field's encoding is wrapped by writeField.
+func (f fieldInfo) transformed() bool {
+	return f.compress || f.encrypt
+}
+
+// Formatter is implemented by types that encode themselves. A nil pointer to a
+// Formatter type is written as NullObject and a non-nil one as the Formatter's
+// output alone, so output that can start with the NullObject byte is read
+// back as nil; formatters for values stored behind pointers should begin with
+// an object header, as generated ones do.
 type Formatter interface {
 	Serialize(writer *Writer) error
 	Deserialize(reader *Reader) error
 }
 
+var (
+	formatterType  = reflect.TypeFor[Formatter]()
+	formatterTypes sync.Map // map[reflect.Type]bool
+)
+
+// isFormatterType reports whether t or a pointer to t implements Formatter.
+// Pointer and interface types are excluded so that nil handling stays with
+// writeValue and readValue.
+func isFormatterType(t reflect.Type) bool {
+	if cached, found := formatterTypes.Load(t); found {
+		return cached.(bool)
+	}
+	is := t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface &&
+		(t.Implements(formatterType) || reflect.PointerTo(t).Implements(formatterType))
+	formatterTypes.Store(t, is)
+	return is
+}
+
+// asFormatter returns v as a Formatter, taking its address when the methods
+// have pointer receivers. Values that are not addressable are copied.
+func asFormatter(v reflect.Value) Formatter {
+	if v.Type().Implements(formatterType) {
+		return v.Interface().(Formatter)
+	}
+	if !v.CanAddr() {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr.Elem()
+	}
+	return v.Addr().Interface().(Formatter)
+}
+
+// zeroStruct replaces the encoding of a struct whose fields are all zero under
+// Options.ZeroStructs. Object headers never use it.
+const zeroStruct = Reserved3
+
+// isZeroMarked reports whether structs are written as zeroStruct when zero
+// under opts. Tuple structs have no header to distinguish the marker from.
+func isZeroMarked(opts *Options) bool {
+	return opts.ZeroStructs && !opts.TupleMode
+}
+
+// pointerPresent precedes the value of a non-nil pointer whose value encoding
+// could otherwise be taken for a null pointer.
+const pointerPresent byte = 1
+
+// hasPresenceMarker reports whether non-nil pointers to t are written with a
+// pointerPresent byte. Tuple structs always need one, since their first field
+// can start with the NullObject byte. With Options.PointerMarkers, so does
+// every other type whose encoding can: integers, floats, strings, collection
+// headers, nil pointers and interfaces and unset Optionals. Bools and structs
+// with an object header never can, and Formatters are left to write their own
+// header.
+func hasPresenceMarker(t reflect.Type, opts *Options) bool {
+	switch {
+	case isFormatterType(t):
+		return false
+	case isTupleStruct(t, opts):
+		return true
+	case !opts.PointerMarkers:
+		return false
+	case isKnownType(t) || isEnumType(t) || isOptionalType(t):
+		return true
+	case t.Kind() == reflect.Struct:
+		return false
+	default:
+		return t.Kind() != reflect.Bool
+	}
+}
+
+// isTupleStruct reports whether values of t are written without an object
+// header under opts.
+func isTupleStruct(t reflect.Type, opts *Options) bool {
+	return opts.TupleMode && !opts.KeyedFields && t.Kind() == reflect.Struct &&
+		!isKnownType(t) && !isFormatterType(t) && !isOptionalType(t)
+}
+
 // serializeStruct serializes a struct to the writer.
 func serializeStruct(writer *Writer, value interface{}) error {
 	v := reflect.ValueOf(value)
@@ -38,19 +149,38 @@ func serializeStruct(writer *Writer, value interface{}) error {
 		return fmt.Errorf("serializeStruct only accepts struct values")
 	}
 
+	if isZeroMarked(&writer.options) && v.IsZero() {
+		writer.WriteByte(zeroStruct)
+		return nil
+	}
+
 	t := v.Type()
-	fd := getFormatterData(t)
+	fd := filterFormatterData(t, &writer.options, &writer.filtered)
+	if writer.options.KeyedFields {
+		return serializeKeyedStruct(writer, v, fd)
+	}
 
-	// Write object header with field count
-	if err := writer.WriteObjectHeader(len(fd.fields)); err != nil {
-		return err
+	// Write object header with field count, which TupleMode leaves implied
+	if !writer.options.TupleMode {
+		if err := writer.WriteObjectHeader(len(fd.fields)); err != nil {
+			return err
+		}
+	}
+
+	if v.CanAddr() && canCopyPOD(fd, &writer.options) {
+		writePOD(writer, v, fd)
+		return nil
+	}
+
+	if writer.options.FieldPresence {
+		return writePresentFields(writer, v, fd.fields)
 	}
 
 	// Write each field
 	for _, field := range fd.fields {
 		fieldValue := v.Field(field.index)
-		if err := writeValue(writer, fieldValue); err != nil {
-			return err
+		if err := writeField(writer, field, fieldValue); err != nil {
+			return withField(err, field.name)
 		}
 	}
 
@@ -69,13 +199,27 @@ func deserializeStruct(reader *Reader, value interface{}) error {
 		return fmt.Errorf("deserializeStruct requires a pointer to a struct")
 	}
 
+	if isZeroMarked(&reader.options) {
+		if b, err := reader.Peek(1); err == nil && b[0] == zeroStruct {
+			reader.pos++
+			v.SetZero()
+			return nil
+		}
+	}
+
 	t := v.Type()
-	fd := getFormatterData(t)
+	fd := filterFormatterData(t, &reader.options, &reader.filtered)
+	if reader.options.KeyedFields {
+		return deserializeKeyedStruct(reader, v, fd)
+	}
 
-	// Read object header
-	fieldCount, isNull, err := reader.ReadObjectHeader()
-	if err != nil {
-		return err
+	// Read object header, or take the field count from the type in TupleMode
+	fieldCount, isNull := len(fd.fields), false
+	var err error
+	if !reader.options.TupleMode {
+		if fieldCount, isNull, err = reader.ReadObjectHeader(); err != nil {
+			return err
+		}
 	}
 
 	if isNull {
@@ -84,20 +228,32 @@ func deserializeStruct(reader *Reader, value interface{}) error {
 	}
 
 	// Verify field count matches
-	if fieldCount != len(fd.fields) {
-		return fmt.Errorf("field count mismatch during deserialization")
+	if fieldCount > len(fd.fields) || fieldCount < len(fd.fields) && !reader.options.AllowMissingFields {
+		return fmt.Errorf("%w during deserialization", ErrFieldCountMismatch)
+	}
+	if err = fillMissingFields(reader, v, fd.fields[fieldCount:]); err != nil {
+		return err
+	}
+	if reader.options.FieldPresence {
+		return readPresentFields(reader, v, fd.fields[:fieldCount])
+	}
+	if fieldCount == len(fd.fields) && canCopyPOD(fd, &reader.options) {
+		if !readPOD(reader, v, fd) {
+			return fmt.Errorf("read error: struct %s needs %d bytes: %w", t, fd.podSize, ErrTruncated)
+		}
+		return nil
 	}
 
 	// Read each field
-	for _, field := range fd.fields {
+	for _, field := range fd.fields[:fieldCount] {
 		fieldValue := v.Field(field.index)
 		if fieldValue.CanSet() {
-			if err = readValue(reader, fieldValue); err != nil {
+			if err = readField(reader, field, fieldValue); err != nil {
 				return err
 			}
 		} else {
 			// Skip over this field in the data
-			if err = skipValue(reader, field.kind); err != nil {
+			if err = skipField(reader, field, fieldValue.Type()); err != nil {
 				return err
 			}
 		}
@@ -106,19 +262,104 @@ func deserializeStruct(reader *Reader, value interface{}) error {
 	return nil
 }
 
-// getFormatterData gets or creates formatter data for a type.
+// fillMissingFields sets fields absent from the stream to the value supplied by
+// the reader's defaults hook, or to their zero value.
+func fillMissingFields(reader *Reader, v reflect.Value, fields []fieldInfo) error {
+	for _, field := range fields {
+		fieldValue := v.Field(field.index)
+		if !fieldValue.CanSet() {
+			continue
+		}
+		fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		if reader.defaults == nil {
+			continue
+		}
+
+		value, ok := reader.defaults(field.name)
+		if !ok {
+			continue
+		}
+		dv := reflect.ValueOf(value)
+		if !dv.IsValid() {
+			continue
+		}
+		if !dv.Type().AssignableTo(fieldValue.Type()) {
+			return fmt.Errorf("default for field %s has type %s, want %s", field.name, dv.Type(), fieldValue.Type())
+		}
+		fieldValue.Set(dv)
+	}
+	return nil
+}
+
+// getFormatterData gets or creates formatter data for a type using the
+// default struct tag and field order.
 func getFormatterData(t reflect.Type) formatterData {
-	if cachedData, found := formatterCache.Load(t); found {
+	return loadFormatterData(formatterKey{typ: t, tagName: defaultTagName})
+}
+
+// loadFormatterData gets or creates the formatter data identified by key.
+func loadFormatterData(key formatterKey) formatterData {
+	if cachedData, found := formatterCache.Load(key); found {
 		return cachedData.(formatterData)
 	}
 
-	fd := createFormatterData(t)
-	formatterCache.Store(t, fd)
+	fd := createFormatterData(key)
+	formatterCache.Store(key, fd)
 	return fd
 }
 
+// TransientFields is implemented by structs that decide at runtime which of
+// their fields are not serialized, such as fields that only exist in some
+// builds or depend on configuration. Transient is called on a pointer to the
+// zero value of the type, once per Serialize or Deserialize call, per
+// Encoder or Decoder, or when a Schema is built, which Validate does once per
+// type, and returns the names of the
+// fields to exclude in addition to those tagged "-". It must give the same
+// answers when decoding as it did when encoding. GenerateFormatter rejects
+// such types, since generated code cannot follow the runtime decision.
+type TransientFields interface {
+	Transient() []string
+}
+
+var transientFieldsType = reflect.TypeFor[TransientFields]()
+
+// filterFormatterData returns the formatter data for t under opts, restricted
+// to the fields accepted by opts.FieldFilter and not listed by t's Transient
+// method.
+//
+// The global formatterCache must not depend on a runtime predicate, so filtered
+// results are cached in cache, which belongs to a single writer or reader.
+func filterFormatterData(t reflect.Type, opts *Options, cache *map[reflect.Type]formatterData) formatterData {
+	fd := loadFormatterData(formatterKey{typ: t, tagName: opts.tagName(), order: opts.FieldOrder})
+	filter := opts.FieldFilter
+	transient := reflect.PointerTo(t).Implements(transientFieldsType)
+	if filter == nil && !transient {
+		return fd
+	}
+	if filtered, found := (*cache)[t]; found {
+		return filtered
+	}
+
+	var excluded []string
+	if transient {
+		excluded = reflect.New(t).Interface().(TransientFields).Transient()
+	}
+	filtered := formatterData{fields: make([]fieldInfo, 0, len(fd.fields)), unknown: fd.unknown}
+	for _, field := range fd.fields {
+		if (filter == nil || filter(t, field.name)) && !slices.Contains(excluded, field.name) {
+			filtered.fields = append(filtered.fields, field)
+		}
+	}
+	if *cache == nil {
+		*cache = make(map[reflect.Type]formatterData)
+	}
+	(*cache)[t] = filtered
+	return filtered
+}
+
 // createFormatterData creates formatter data for a type.
-func createFormatterData(t reflect.Type) formatterData {
+func createFormatterData(key formatterKey) formatterData {
+	t, tagName := key.typ, key.tagName
 	fd := formatterData{
 		fields: make([]fieldInfo, 0, t.NumField()),
 	}
@@ -130,9 +371,10 @@ func createFormatterData(t reflect.Type) formatterData {
 			continue
 		}
 
-		// Check tag for order
+		// Check tag for order and options
 		order := i
-		tag := field.Tag.Get("memorypack")
+		compress, encrypt, unknown := false, false, false
+		tag := field.Tag.Get(tagName)
 		if tag != "" && tag != "-" {
 			parts := strings.Split(tag, ",")
 			if orderStr := parts[0]; orderStr != "" {
@@ -140,6 +382,20 @@ func createFormatterData(t reflect.Type) formatterData {
 					order = parsedOrder
 				}
 			}
+			for _, option := range parts[1:] {
+				switch option {
+				case "gzip":
+					compress = true
+				case "encrypt":
+					encrypt = true
+				case "unknown":
+					unknown = field.Type == unknownFieldsType
+				}
+			}
+		}
+		if unknown {
+			fd.unknown = field.Index
+			continue
 		}
 
 		// Skip fields that are not tagged or tagged with '-'
@@ -148,27 +404,58 @@ func createFormatterData(t reflect.Type) formatterData {
 		}
 
 		fd.fields = append(fd.fields, fieldInfo{
-			index: i,
-			kind:  field.Type.Kind(),
-			name:  field.Name,
-			order: order,
+			index:    i,
+			kind:     field.Type.Kind(),
+			name:     field.Name,
+			order:    order,
+			compress: compress,
+			encrypt:  encrypt,
 		})
 	}
 
-	// Sort fields by the specified order
-	sort.Slice(fd.fields, func(i, j int) bool {
-		return fd.fields[i].order < fd.fields[j].order
-	})
+	sortFields(fd.fields, key.order)
+	fd.podSize, fd.podBools = podLayout(t, fd.fields)
 
 	return fd
 }
 
+// sortFields sorts fields into the order they are written in.
+func sortFields(fields []fieldInfo, order FieldOrder) {
+	switch order {
+	case FieldOrderDeclaration:
+		sort.Slice(fields, func(i, j int) bool { return fields[i].index < fields[j].index })
+	case FieldOrderReverse:
+		sort.Slice(fields, func(i, j int) bool { return fields[i].index > fields[j].index })
+	case FieldOrderAlphabetical:
+		sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	default:
+		sort.Slice(fields, func(i, j int) bool { return fields[i].order < fields[j].order })
+	}
+}
+
 // writeValue handles writing any reflected value.
 func writeValue(writer *Writer, v reflect.Value) error {
 	if err := writer.CheckDepth(); err != nil {
 		return err
 	}
 	defer writer.EndCheckDepth()
+	if handled, err := writeKnownType(writer, v); handled {
+		return err
+	}
+	if handled, err := writeEnum(writer, v); handled {
+		return err
+	}
+	if handled, err := writeOptional(writer, v); handled {
+		return err
+	}
+	if isFormatterType(v.Type()) {
+		return asFormatter(v).Serialize(writer)
+	}
+	if writer.options.TaggedPrimitives {
+		if tag, ok := primitiveTag(v.Kind()); ok {
+			writer.WriteByte(tag)
+		}
+	}
 	switch v.Kind() {
 	case reflect.Bool:
 		writer.WriteBool(v.Bool())
@@ -180,36 +467,42 @@ func writeValue(writer *Writer, v reflect.Value) error {
 		writer.WriteInt32(int32(v.Int()))
 	case reflect.Int, reflect.Int64:
 		writer.WriteInt64(v.Int())
+	case reflect.Uint8:
+		writer.WriteByte(byte(v.Uint()))
+	case reflect.Uint16:
+		writer.WriteInt16(int16(v.Uint()))
+	case reflect.Uint32:
+		writer.WriteInt32(int32(v.Uint()))
+	case reflect.Uint, reflect.Uint64:
+		writer.WriteInt64(int64(v.Uint()))
 	case reflect.Float32:
+		if err := checkFinite(writer, v.Float()); err != nil {
+			return err
+		}
 		writer.WriteFloat32(float32(v.Float()))
 	case reflect.Float64:
+		if err := checkFinite(writer, v.Float()); err != nil {
+			return err
+		}
 		writer.WriteFloat64(v.Float())
 	case reflect.String:
+		if err := checkCollectionLength(v.Len()); err != nil {
+			return err
+		}
 		writer.WriteString(v.String())
 	case reflect.Slice:
-		if v.IsNil() {
-			writer.WriteNullCollectionHeader()
-			return nil
-		}
-
-		if v.Type().Elem().Kind() == reflect.Uint8 {
-			// []byte has special treatment
-			writer.WriteBytes(v.Bytes())
-		} else {
-			// Other slices
-			writer.WriteCollectionHeader(v.Len())
-			for i := 0; i < v.Len(); i++ {
-				if err := writeValue(writer, v.Index(i)); err != nil {
-					return err
-				}
-			}
+		if writer.options.ShareSlices {
+			return writeSharedSlice(writer, v)
 		}
+		return writeSlice(writer, v)
 	case reflect.Array:
 		length := v.Len()
-		writer.WriteCollectionHeader(length)
+		if err := writer.WriteCollectionHeader(length); err != nil {
+			return err
+		}
 		for i := range length {
 			if err := writeValue(writer, v.Index(i)); err != nil {
-				return err
+				return withIndex(err, i)
 			}
 		}
 	case reflect.Map:
@@ -218,33 +511,133 @@ func writeValue(writer *Writer, v reflect.Value) error {
 			return nil
 		}
 
-		writer.WriteCollectionHeader(v.Len())
-		if v.Len() > 0 {
-			iter := v.MapRange()
-			for iter.Next() {
-				if err := writeValue(writer, iter.Key()); err != nil {
-					return err
-				}
-				if err := writeValue(writer, iter.Value()); err != nil {
+		if err := writer.WriteCollectionHeader(v.Len()); err != nil {
+			return err
+		}
+		if isDenseMap(v.Type(), &writer.options) {
+			if dense, err := writeDenseMap(writer, v); dense || err != nil {
+				return err
+			}
+		}
+		seen := newTimeKeySet(v)
+		if writer.options.CanonicalMaps {
+			keys, err := sortedMapKeys(v)
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err = writeMapEntry(writer, key, v.MapIndex(key), seen); err != nil {
 					return err
 				}
 			}
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := writeMapEntry(writer, iter.Key(), iter.Value(), seen); err != nil {
+				return err
+			}
 		}
 	case reflect.Struct:
+		if v.CanAddr() {
+			return serializeStruct(writer, v.Addr().Interface())
+		}
 		return serializeStruct(writer, v.Interface())
 	case reflect.Ptr:
-		if !v.IsNil() {
-			return writeValue(writer, v.Elem())
+		if v.IsNil() {
+			writer.WriteByte(NullObject)
+			return nil
+		}
+		if writer.options.TrackReferences {
+			return writeReference(writer, v)
 		}
-		writer.WriteByte(NullObject)
+		if hasPresenceMarker(v.Type().Elem(), &writer.options) {
+			writer.WriteByte(pointerPresent)
+		}
+		return writeValue(writer, v.Elem())
+	case reflect.Interface:
+		return writeInterface(writer, v)
+	case reflect.Func:
+		return writeFunc(writer, v)
 	default:
-		return fmt.Errorf("unsupported type: %s", v.Kind())
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Kind())
+	}
+	return nil
+}
+
+// writeMapEntry writes one key and value of a map, recording the key in seen
+// if it is not nil.
+func writeMapEntry(writer *Writer, key, value reflect.Value, seen timeKeySet) error {
+	start := writer.pos
+	if err := writeMapKey(writer, key); err != nil {
+		return withKey(err, key)
+	}
+	if err := seen.add(writer.buffer[start:writer.pos]); err != nil {
+		return withKey(err, key)
+	}
+	if err := writeValue(writer, value); err != nil {
+		return withKey(err, key)
+	}
+	return nil
+}
+
+// writeSlice writes a slice value.
+func writeSlice(writer *Writer, v reflect.Value) error {
+	if v.IsNil() {
+		writer.WriteNullCollectionHeader()
+		return nil
+	}
+
+	switch {
+	case v.Type().Elem().Kind() == reflect.Uint8 && !isEnumType(v.Type().Elem()):
+		// []byte has special treatment
+		if err := checkCollectionLength(v.Len()); err != nil {
+			return err
+		}
+		writer.WriteBytes(v.Bytes())
+	case v.Type().Elem().Kind() == reflect.Bool && writer.options.PackBools:
+		return writePackedBools(writer, v)
+	case isDeltaSlice(v.Type(), &writer.options):
+		return writeDeltaInts(writer, v)
+	default:
+		// Other slices
+		if err := writer.WriteCollectionHeader(v.Len()); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := writeValue(writer, v.Index(i)); err != nil {
+				return withIndex(err, i)
+			}
+		}
 	}
 	return nil
 }
 
 // readValue handles reading any reflected value.
 func readValue(reader *Reader, v reflect.Value) error {
+	if err := reader.enterDepth(); err != nil {
+		return err
+	}
+	defer func() { reader.depth-- }()
+	if handled, err := readKnownType(reader, v); handled {
+		return err
+	}
+	if handled, err := readEnum(reader, v); handled {
+		return err
+	}
+	if handled, err := readOptional(reader, v); handled {
+		return err
+	}
+	if isFormatterType(v.Type()) {
+		return asFormatter(v).Deserialize(reader)
+	}
+	if reader.options.TaggedPrimitives {
+		if tag, ok := primitiveTag(v.Kind()); ok {
+			if handled, err := readTaggedPrimitive(reader, v, tag); handled || err != nil {
+				return err
+			}
+		}
+	}
 	switch v.Kind() {
 	case reflect.Bool:
 		val, err := reader.ReadBool()
@@ -276,6 +669,30 @@ func readValue(reader *Reader, v reflect.Value) error {
 			return err
 		}
 		v.SetInt(val)
+	case reflect.Uint8:
+		val, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		return setUint(v, uint64(val))
+	case reflect.Uint16:
+		val, err := reader.ReadInt16()
+		if err != nil {
+			return err
+		}
+		return setUint(v, uint64(uint16(val)))
+	case reflect.Uint32:
+		val, err := reader.ReadInt32()
+		if err != nil {
+			return err
+		}
+		return setUint(v, uint64(uint32(val)))
+	case reflect.Uint, reflect.Uint64:
+		val, err := reader.ReadInt64()
+		if err != nil {
+			return err
+		}
+		return setUint(v, uint64(val))
 	case reflect.Float32:
 		val, err := reader.ReadFloat32()
 		if err != nil {
@@ -295,32 +712,10 @@ func readValue(reader *Reader, v reflect.Value) error {
 		}
 		v.SetString(val)
 	case reflect.Slice:
-		if v.Type().Elem().Kind() == reflect.Uint8 {
-			// []byte has special treatment
-			bytes, err := reader.ReadBytes()
-			if err != nil {
-				return err
-			}
-			v.SetBytes(bytes)
-		} else {
-			// Other slices
-			length, isNull, err := reader.ReadCollectionHeader()
-			if err != nil {
-				return err
-			}
-			if isNull {
-				v.Set(reflect.Zero(v.Type()))
-				return nil
-			}
-
-			slice := reflect.MakeSlice(v.Type(), length, length)
-			for i := range length {
-				if err = readValue(reader, slice.Index(i)); err != nil {
-					return err
-				}
-			}
-			v.Set(slice)
+		if reader.options.ShareSlices {
+			return readSharedSlice(reader, v)
 		}
+		return readSlice(reader, v)
 	case reflect.Array:
 		length, isNull, err := reader.ReadCollectionHeader()
 		if err != nil {
@@ -330,11 +725,32 @@ func readValue(reader *Reader, v reflect.Value) error {
 			// Can't set nil to array, so skip
 			return nil
 		}
+		if length != v.Len() && reader.options.StrictArrayLength {
+			return fmt.Errorf("array of length %d cannot hold %d elements", v.Len(), length)
+		}
 
-		for i := range length {
+		n := min(length, v.Len())
+		for i := range n {
 			if err = readValue(reader, v.Index(i)); err != nil {
 				return err
 			}
+			reader.reportProgress()
+		}
+		for i := n; i < v.Len(); i++ {
+			v.Index(i).SetZero()
+		}
+		if length > n {
+			// Decode and discard the elements that do not fit.
+			if err = reader.checkLength(length-n, v.Type().Elem()); err != nil {
+				return err
+			}
+			extra := reflect.New(v.Type().Elem()).Elem()
+			for range length - n {
+				extra.SetZero()
+				if err = readValue(reader, extra); err != nil {
+					return err
+				}
+			}
 		}
 	case reflect.Map:
 		length, isNull, err := reader.ReadCollectionHeader()
@@ -347,6 +763,17 @@ func readValue(reader *Reader, v reflect.Value) error {
 		}
 
 		mapType := v.Type()
+		if err = reader.checkLength(length, mapType.Key()); err != nil {
+			return err
+		}
+		if err = reader.allocate(length, mapType.Key().Size()+mapType.Elem().Size()); err != nil {
+			return err
+		}
+		if isDenseMap(mapType, &reader.options) {
+			if dense, err := readDenseMap(reader, v, length); dense || err != nil {
+				return err
+			}
+		}
 		mapValue := reflect.MakeMapWithSize(mapType, length)
 
 		for range length {
@@ -356,20 +783,37 @@ func readValue(reader *Reader, v reflect.Value) error {
 			key := reflect.New(keyType).Elem()
 			value := reflect.New(valueType).Elem()
 
-			if err = readValue(reader, key); err != nil {
+			if err = readMapKey(reader, key); err != nil {
 				return err
 			}
+			if !key.Comparable() {
+				return fmt.Errorf("map key of type %s is not hashable", key.Type())
+			}
 			if err = readValue(reader, value); err != nil {
 				return err
 			}
 
+			if reader.options.RejectDuplicateKeys && mapValue.MapIndex(key).IsValid() {
+				return fmt.Errorf("duplicate map key %v", key)
+			}
 			mapValue.SetMapIndex(key, value)
+			reader.reportProgress()
+		}
+		if reader.options.RejectDuplicateKeys && mapValue.Len() != length {
+			return fmt.Errorf("map has %d distinct keys, header declares %d", mapValue.Len(), length)
 		}
 
 		v.Set(mapValue)
 	case reflect.Struct:
+		// Tuple structs have no header to tell a pointer encoding apart by.
+		if !isTupleStruct(v.Type(), &reader.options) && isPointerEncoded(reader) {
+			return readPointerAsValue(reader, v)
+		}
 		return deserializeStruct(reader, v.Addr().Interface())
 	case reflect.Ptr:
+		if reader.options.TrackReferences {
+			return readReference(reader, v)
+		}
 		b, err := reader.Peek(1)
 		if err != nil {
 			return err
@@ -383,87 +827,248 @@ func readValue(reader *Reader, v reflect.Value) error {
 			v.Set(reflect.Zero(v.Type()))
 			return nil
 		}
+		if hasPresenceMarker(v.Type().Elem(), &reader.options) {
+			if b[0] != pointerPresent {
+				return fmt.Errorf("%w: pointer marker %d for %s", ErrInvalidHeader, b[0], v.Type().Elem())
+			}
+			reader.pos++
+		}
 		// Object with members
 		if v.IsNil() {
+			if err = reader.allocate(1, v.Type().Elem().Size()); err != nil {
+				return err
+			}
 			v.Set(reflect.New(v.Type().Elem()))
 		}
 		return readValue(reader, v.Elem())
+	case reflect.Interface:
+		return readInterface(reader, v)
+	case reflect.Func:
+		return readFunc(reader, v)
 	default:
-		return fmt.Errorf("unsupported type: %s", v.Kind())
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Kind())
 	}
 	return nil
 }
 
-// skipValue skips over a value in the reader.
-func skipValue(reader *Reader, kind reflect.Kind) error {
-	switch kind {
-	case reflect.Bool, reflect.Int8, reflect.Uint8:
-		_, err := reader.ReadByte()
-		return err
-	case reflect.Int16, reflect.Uint16:
-		_, err := reader.ReadInt16()
-		return err
-	case reflect.Int32, reflect.Uint32, reflect.Float32:
-		_, err := reader.ReadInt32()
-		return err
-	case reflect.Int64, reflect.Uint64, reflect.Float64:
-		_, err := reader.ReadInt64()
-		return err
-	case reflect.String:
-		_, err := reader.ReadString()
-		return err
-	case reflect.Slice, reflect.Array:
-		length, isNull, err := reader.ReadCollectionHeader()
+// readSlice reads a slice value.
+func readSlice(reader *Reader, v reflect.Value) error {
+	switch {
+	case v.Type().Elem().Kind() == reflect.Uint8 && !isEnumType(v.Type().Elem()):
+		// []byte has special treatment
+		bytes, err := reader.ReadBytes()
 		if err != nil {
 			return err
 		}
-		if !isNull {
-			for range length {
-				// Assuming int32 elements for simple skipping
-				if _, err = reader.ReadInt32(); err != nil {
-					return err
-				}
+		for _, b := range bytes {
+			if err = checkFlagMask(v.Type().Elem(), uint64(b)); err != nil {
+				return err
 			}
 		}
-		return nil
-	case reflect.Map:
+		v.SetBytes(bytes)
+	case v.Type().Elem().Kind() == reflect.Bool:
+		// Packed bools are recognized by their header whatever the options.
+		return readBoolSlice(reader, v)
+	case isDeltaSlice(v.Type(), &reader.options):
+		return readDeltaInts(reader, v)
+	default:
+		// Other slices
 		length, isNull, err := reader.ReadCollectionHeader()
 		if err != nil {
 			return err
 		}
-		if !isNull {
-			for range length {
-				// Skip key and value (assuming strings for simplicity)
-				if _, err = reader.ReadString(); err != nil {
-					return err
-				}
-				_, err = reader.ReadString()
-				if err != nil {
-					return err
-				}
+		if isNull {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		if err = reader.checkLength(length, v.Type().Elem()); err != nil {
+			return err
+		}
+		if err = reader.allocate(length, v.Type().Elem().Size()); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(v.Type(), length, length)
+		for i := range length {
+			if err = readValue(reader, slice.Index(i)); err != nil {
+				return err
 			}
+			reader.reportProgress()
 		}
+		v.Set(slice)
+	}
+	return nil
+}
+
+// checkFinite rejects NaN and infinite floats when RejectNonFinite is set.
+func checkFinite(writer *Writer, f float64) error {
+	if writer.options.RejectNonFinite && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return fmt.Errorf("float value %v is not finite", f)
+	}
+	return nil
+}
+
+// isPointerEncoded reports whether the next value was written as a pointer
+// rather than a struct. Object headers never use these bytes, so data written
+// when a field was a pointer can be told apart from a struct value.
+func isPointerEncoded(reader *Reader) bool {
+	b, err := reader.Peek(1)
+	if err != nil {
+		return false
+	}
+	return b[0] == NullObject || reader.options.TrackReferences && (b[0] == ReferenceID || b[0] == ReferenceNew)
+}
+
+// readPointerAsValue decodes a pointer encoding into the struct value v, for
+// data written when the field was a pointer. Null decodes as the zero value.
+func readPointerAsValue(reader *Reader, v reflect.Value) error {
+	ptr := reflect.New(reflect.PointerTo(v.Type())).Elem()
+	if err := readValue(reader, ptr); err != nil {
+		return err
+	}
+	if ptr.IsNil() {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(ptr.Elem())
+	}
+	return nil
+}
+
+// writeMapKey writes a map key, using zigzag varints for signed integer keys
+// when CompactMapKeys is set.
+func writeMapKey(writer *Writer, key reflect.Value) error {
+	if writer.options.CompactMapKeys && isSignedInt(key.Kind()) {
+		writer.WriteVarint(key.Int())
 		return nil
-	case reflect.Struct:
-		// Skip object header
-		_, isNull, err := reader.ReadObjectHeader()
+	}
+	return writeValue(writer, key)
+}
+
+// readMapKey reads a map key written by writeMapKey.
+func readMapKey(reader *Reader, key reflect.Value) error {
+	if reader.options.CompactMapKeys && isSignedInt(key.Kind()) {
+		val, err := reader.ReadVarint()
 		if err != nil {
 			return err
 		}
-		if !isNull {
-			return fmt.Errorf("skipping struct fields not fully implemented")
+		if key.OverflowInt(val) {
+			return fmt.Errorf("map key %d overflows %s", val, key.Type())
 		}
+		key.SetInt(val)
 		return nil
-	case reflect.Ptr:
-		header, err := reader.ReadByte()
-		if err != nil {
+	}
+	return readValue(reader, key)
+}
+
+// isSignedInt reports whether kind is a signed integer kind.
+func isSignedInt(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isUnsignedInt reports whether kind is an unsigned integer kind.
+func isUnsignedInt(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// skipField skips over the encoding of a field that cannot be set, undoing
+// the field's compression or encryption first.
+func skipField(reader *Reader, field fieldInfo, t reflect.Type) error {
+	if field.transformed() {
+		return readField(reader, field, reflect.New(t).Elem())
+	}
+	return skipValue(reader, t)
+}
+
+// skipValue skips over a value of type t in the reader. Fixed-width values and
+// strings are discarded, and the elements of slices, arrays and maps are
+// skipped one by one. Values whose layout depends on a hook or an option are
+// decoded into a scratch value instead.
+func skipValue(reader *Reader, t reflect.Type) error {
+	if isKnownType(t) || isEnumType(t) || isOptionalType(t) || isFormatterType(t) {
+		return readValue(reader, reflect.New(t).Elem())
+	}
+	opts := &reader.options
+	if _, tagged := primitiveTag(t.Kind()); tagged && opts.TaggedPrimitives {
+		return readValue(reader, reflect.New(t).Elem())
+	}
+
+	switch kind := t.Kind(); {
+	case kind == reflect.Bool || kind == reflect.Int8 || kind == reflect.Uint8:
+		_, err := reader.Discard(1)
+		return err
+	case kind == reflect.Int16 || kind == reflect.Uint16:
+		_, err := reader.Discard(2)
+		return err
+	case kind == reflect.Int32 || kind == reflect.Uint32 || kind == reflect.Float32:
+		_, err := reader.Discard(4)
+		return err
+	case kind == reflect.Int || kind == reflect.Uint || kind == reflect.Int64 || kind == reflect.Uint64 || kind == reflect.Float64:
+		_, err := reader.Discard(8)
+		return err
+	case kind == reflect.String:
+		if opts.CompactStrings {
+			if _, short, err := reader.readShortString(); short || err != nil {
+				return err
+			}
+		}
+		header, err := reader.ReadInt32()
+		if err != nil || header >= 0 || header == NullCollection {
+			return err
+		}
+		// Skip the length and the ~header UTF-8 bytes.
+		_, err = reader.Discard(4 + int(^header))
+		return err
+	case kind == reflect.Slice && !opts.ShareSlices && t.Elem().Kind() == reflect.Uint8 && !isEnumType(t.Elem()):
+		length, isNull, err := reader.ReadCollectionHeader()
+		if err != nil || isNull {
 			return err
 		}
-		if header != NullObject {
-			return fmt.Errorf("skipping pointer values not fully implemented")
+		_, err = reader.Discard(length)
+		return err
+	case kind == reflect.Slice && !opts.ShareSlices && t.Elem().Kind() != reflect.Bool && !isDeltaSlice(t, opts),
+		kind == reflect.Array:
+		length, isNull, err := reader.ReadCollectionHeader()
+		if err != nil || isNull {
+			return err
+		}
+		if err = reader.checkLength(length, t.Elem()); err != nil {
+			return err
+		}
+		for i := range length {
+			if err = skipValue(reader, t.Elem()); err != nil {
+				return withIndex(err, i)
+			}
+		}
+		return nil
+	case kind == reflect.Map && !opts.CompactMapKeys && !isDenseMap(t, opts):
+		length, isNull, err := reader.ReadCollectionHeader()
+		if err != nil || isNull {
+			return err
+		}
+		if err = reader.checkLength(length, t.Key()); err != nil {
+			return err
+		}
+		for range length {
+			if err = skipValue(reader, t.Key()); err != nil {
+				return err
+			}
+			if err = skipValue(reader, t.Elem()); err != nil {
+				return err
+			}
 		}
 		return nil
 	default:
-		return fmt.Errorf("skipping unsupported type: %s", kind)
+		// Structs, pointers, interfaces and collections with an optional layout.
+		return readValue(reader, reflect.New(t).Elem())
 	}
 }
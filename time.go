@@ -0,0 +1,277 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// TimeEncoding selects the wire layout of time.Time values.
+type TimeEncoding byte
+
+const (
+	// TimeEncodingUnix writes a precision marker followed by varints of Unix
+	// seconds and sub-second units. It is compact but specific to this package.
+	TimeEncodingUnix TimeEncoding = iota
+	// TimeEncodingDateTime uses the layout of C# DateTime: an int64 holding
+	// 100ns ticks since 0001-01-01 in the low 62 bits and the kind in the top two.
+	// Times are written as UTC.
+	TimeEncodingDateTime
+	// TimeEncodingDateTimeOffset uses the layout of C# DateTimeOffset: the int64
+	// ticks of the local clock time followed by the int16 UTC offset in minutes.
+	TimeEncodingDateTimeOffset
+	// TimeEncodingString writes times as strings formatted with
+	// Options.TimeLayout and parses them back with time.Parse. It is larger
+	// but readable by other tools; the layout decides what precision is kept.
+	TimeEncodingString
+)
+
+const (
+	ticksPerSecond    = 10_000_000
+	nanosPerTick      = 100
+	unixEpochTicks    = 621_355_968_000_000_000 // ticks at 1970-01-01
+	dateTimeKindUTC   = 1
+	dateTimeKindLocal = 2
+	dateTimeTicksMask = 1<<62 - 1 // mask of the ticks in a C# DateTime
+)
+
+// TimePrecision selects the resolution used to encode time.Time values.
+//
+// The precision is written before each value, so data can be decoded
+// regardless of the precision the reader is configured with.
+type TimePrecision byte
+
+const (
+	// TimeNanoseconds keeps the full resolution of time.Time.
+	TimeNanoseconds TimePrecision = iota
+	// TimeMilliseconds truncates times to whole milliseconds.
+	TimeMilliseconds
+	// TimeSeconds truncates times to whole seconds.
+	TimeSeconds
+)
+
+// WriteTime writes a time.Time using the writer's TimeEncoding.
+func (w *Writer) WriteTime(t time.Time) error {
+	if err := w.writeTimeInstant(t); err != nil {
+		return err
+	}
+	if w.options.TimeZoneNames {
+		writeTimeZone(w, t)
+	}
+	return nil
+}
+
+// writeTimeInstant writes the instant and offset of t in the writer's TimeEncoding.
+func (w *Writer) writeTimeInstant(t time.Time) error {
+	switch w.options.TimeEncoding {
+	case TimeEncodingUnix:
+		return writeUnixTime(w, t)
+	case TimeEncodingDateTime:
+		w.WriteInt64(timeToTicks(t) | dateTimeKindUTC<<62)
+	case TimeEncodingDateTimeOffset:
+		_, offset := t.Zone()
+		if offset%60 != 0 {
+			return fmt.Errorf("UTC offset of %ds is not a whole number of minutes", offset)
+		}
+		w.WriteInt64(timeToTicks(t) + int64(offset)*ticksPerSecond)
+		w.WriteInt16(int16(offset / 60))
+	case TimeEncodingString:
+		w.WriteString(t.Format(w.options.timeLayout()))
+	default:
+		return fmt.Errorf("invalid time encoding %d", w.options.TimeEncoding)
+	}
+	return nil
+}
+
+// ReadTime reads a time.Time written by WriteTime with the same TimeEncoding.
+func (r *Reader) ReadTime() (time.Time, error) {
+	t, err := r.readTimeInstant()
+	if err != nil || !r.options.TimeZoneNames {
+		return t, err
+	}
+	loc, err := readTimeZone(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// readTimeInstant reads a time written by writeTimeInstant.
+func (r *Reader) readTimeInstant() (time.Time, error) {
+	switch r.options.TimeEncoding {
+	case TimeEncodingUnix:
+		return readUnixTime(r)
+	case TimeEncodingDateTime:
+		data, err := r.ReadInt64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := ticksToTime(data & dateTimeTicksMask)
+		if uint64(data)>>62 == dateTimeKindLocal {
+			// The ticks are a local clock reading rather than an instant.
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+		}
+		return t, nil
+	case TimeEncodingDateTimeOffset:
+		ticks, err := r.ReadInt64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		minutes, err := r.ReadInt16()
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := ticksToTime(ticks - int64(minutes)*60*ticksPerSecond)
+		if minutes != 0 {
+			t = t.In(time.FixedZone("", int(minutes)*60))
+		}
+		return t, nil
+	case TimeEncodingString:
+		s, err := r.ReadString()
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(r.options.timeLayout(), s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q for layout %q: %w", s, r.options.timeLayout(), err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid time encoding %d", r.options.TimeEncoding)
+	}
+}
+
+// writeTimeZone writes the name of the location of t, followed by its UTC
+// offset at t for locations that cannot be loaded by name.
+func writeTimeZone(writer *Writer, t time.Time) {
+	_, offset := t.Zone()
+	writer.WriteString(t.Location().String())
+	writer.WriteVarint(int64(offset))
+}
+
+// readTimeZone reads a location written by writeTimeZone. "UTC" and "Local"
+// map to time.UTC and time.Local, and other names are loaded with
+// time.LoadLocation. Names that cannot be loaded, such as those of fixed
+// zones, become a fixed zone with the written offset.
+func readTimeZone(reader *Reader) (*time.Location, error) {
+	name, err := reader.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := reader.ReadVarint()
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "UTC":
+		return time.UTC, nil
+	case "Local":
+		return time.Local, nil
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc, nil
+	}
+	return time.FixedZone(name, int(offset)), nil
+}
+
+// timeToTicks returns the number of 100ns ticks from 0001-01-01 UTC to t.
+func timeToTicks(t time.Time) int64 {
+	return unixEpochTicks + t.Unix()*ticksPerSecond + int64(t.Nanosecond())/nanosPerTick
+}
+
+// ticksToTime converts ticks since 0001-01-01 to a UTC time.
+func ticksToTime(ticks int64) time.Time {
+	sinceEpoch := ticks - unixEpochTicks
+	seconds, rem := sinceEpoch/ticksPerSecond, sinceEpoch%ticksPerSecond
+	if rem < 0 {
+		seconds--
+		rem += ticksPerSecond
+	}
+	return time.Unix(seconds, rem*nanosPerTick).UTC()
+}
+
+// writeUnixTime writes t as a precision marker, a varint of Unix seconds and,
+// unless the precision is seconds, a uvarint of the sub-second part.
+func writeUnixTime(writer *Writer, t time.Time) error {
+	precision := writer.options.TimePrecision
+	var fraction uint64
+	switch precision {
+	case TimeNanoseconds:
+		fraction = uint64(t.Nanosecond())
+	case TimeMilliseconds:
+		fraction = uint64(t.Nanosecond() / int(time.Millisecond))
+	case TimeSeconds:
+	default:
+		return fmt.Errorf("invalid time precision %d", precision)
+	}
+
+	writer.WriteByte(byte(precision))
+	writer.WriteVarint(t.Unix())
+	if precision != TimeSeconds {
+		writer.WriteUvarint(fraction)
+	}
+	return nil
+}
+
+// readUnixTime reads a time written by writeUnixTime. Times are returned in UTC.
+func readUnixTime(reader *Reader) (time.Time, error) {
+	marker, err := reader.ReadByte()
+	if err != nil {
+		return time.Time{}, err
+	}
+	seconds, err := reader.ReadVarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var unit, limit uint64
+	switch TimePrecision(marker) {
+	case TimeNanoseconds:
+		unit, limit = 1, uint64(time.Second)
+	case TimeMilliseconds:
+		unit, limit = uint64(time.Millisecond), 1000
+	case TimeSeconds:
+		return time.Unix(seconds, 0).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid time precision marker %d", marker)
+	}
+
+	fraction, err := reader.ReadUvarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if fraction >= limit {
+		return time.Time{}, fmt.Errorf("invalid sub-second time value %d", fraction)
+	}
+	return time.Unix(seconds, int64(fraction*unit)).UTC(), nil
+}
+
+// timeKeySet holds the encodings of the time.Time keys of a map written so
+// far. Times in different locations, or with monotonic readings, are distinct
+// keys in Go but may encode alike, and would collapse into one entry when
+// decoded.
+type timeKeySet map[string]struct{}
+
+// newTimeKeySet returns an empty set if map v has time.Time keys, or nil.
+func newTimeKeySet(v reflect.Value) timeKeySet {
+	if v.Type().Key() != timeType {
+		return nil
+	}
+	return make(timeKeySet, v.Len())
+}
+
+// add records an encoded key, rejecting one encoded before. It does nothing
+// on a nil set.
+func (s timeKeySet) add(encoded []byte) error {
+	if s == nil {
+		return nil
+	}
+	if _, found := s[string(encoded)]; found {
+		return fmt.Errorf("map has two time keys with the same encoding")
+	}
+	s[string(encoded)] = struct{}{}
+	return nil
+}
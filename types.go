@@ -0,0 +1,279 @@
+package memorypack
+
+import (
+	"bytes"
+	"container/ring"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	urlType       = reflect.TypeFor[url.URL]()
+	urlPtrType    = reflect.TypeFor[*url.URL]()
+	syncMapType   = reflect.TypeFor[sync.Map]()
+	ringPtrType   = reflect.TypeFor[*ring.Ring]()
+	errorType     = reflect.TypeFor[error]()
+	bufferType    = reflect.TypeFor[bytes.Buffer]()
+	bufferPtrType = reflect.TypeFor[*bytes.Buffer]()
+)
+
+// isKnownType reports whether t is handled by writeKnownType and readKnownType.
+func isKnownType(t reflect.Type) bool {
+	switch t {
+	case urlType, urlPtrType, timeType, syncMapType, ringPtrType, errorType, addrType, addrPortType, bufferType, bufferPtrType:
+		return true
+	}
+	return false
+}
+
+// writeKnownType writes values of standard library types whose internals do not
+// round-trip through reflection. It reports whether v was handled.
+func writeKnownType(writer *Writer, v reflect.Value) (bool, error) {
+	switch v.Type() {
+	case urlPtrType:
+		if v.IsNil() {
+			writer.WriteNullCollectionHeader()
+			return true, nil
+		}
+		writer.WriteString(v.Interface().(*url.URL).String())
+	case urlType:
+		u := v.Interface().(url.URL)
+		writer.WriteString(u.String())
+	case timeType:
+		return true, writer.WriteTime(v.Interface().(time.Time))
+	case syncMapType:
+		if !v.CanAddr() {
+			return true, fmt.Errorf("cannot serialize a sync.Map that is not addressable")
+		}
+		return true, writeSyncMap(writer, v.Addr().Interface().(*sync.Map))
+	case ringPtrType:
+		return true, writeRing(writer, v.Interface().(*ring.Ring))
+	case errorType:
+		err, _ := v.Interface().(error)
+		return true, writeError(writer, err)
+	case addrType:
+		writeAddr(writer, v.Interface().(netip.Addr))
+	case addrPortType:
+		writeAddrPort(writer, v.Interface().(netip.AddrPort))
+	case bufferPtrType:
+		if v.IsNil() {
+			writer.WriteBytes(nil)
+			return true, nil
+		}
+		b := v.Interface().(*bytes.Buffer).Bytes()
+		if b == nil {
+			// An empty buffer may hold a nil slice, which must not read back as nil.
+			b = []byte{}
+		}
+		writer.WriteBytes(b)
+	case bufferType:
+		if !v.CanAddr() {
+			return true, fmt.Errorf("cannot serialize a bytes.Buffer that is not addressable")
+		}
+		writer.WriteBytes(v.Addr().Interface().(*bytes.Buffer).Bytes())
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// readKnownType reads values written by writeKnownType. It reports whether v
+// was handled.
+func readKnownType(reader *Reader, v reflect.Value) (bool, error) {
+	switch v.Type() {
+	case urlPtrType:
+		if isNull, err := readNullCollection(reader); err != nil || isNull {
+			if isNull {
+				v.Set(reflect.Zero(v.Type()))
+			}
+			return true, err
+		}
+		u, err := readURL(reader)
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(u))
+	case urlType:
+		u, err := readURL(reader)
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(*u))
+	case timeType:
+		t, err := reader.ReadTime()
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(t))
+	case syncMapType:
+		return true, readSyncMap(reader, v.Addr().Interface().(*sync.Map))
+	case ringPtrType:
+		r, err := readRing(reader)
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(r))
+	case errorType:
+		decoded, err := readError(reader)
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(&decoded).Elem())
+	case addrType:
+		addr, err := readAddr(reader)
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(addr))
+	case addrPortType:
+		ap, err := readAddrPort(reader)
+		if err != nil {
+			return true, err
+		}
+		v.Set(reflect.ValueOf(ap))
+	case bufferPtrType:
+		b, err := reader.ReadBytes()
+		if err != nil {
+			return true, err
+		}
+		if b == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return true, nil
+		}
+		v.Set(reflect.ValueOf(bytes.NewBuffer(b)))
+	case bufferType:
+		b, err := reader.ReadBytes()
+		if err != nil {
+			return true, err
+		}
+		buf := v.Addr().Interface().(*bytes.Buffer)
+		buf.Reset()
+		buf.Write(b)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// readNullCollection consumes a null collection header if one comes next.
+func readNullCollection(reader *Reader) (bool, error) {
+	header, err := reader.Peek(4)
+	if err != nil {
+		return false, err
+	}
+	if int32(binary.LittleEndian.Uint32(header)) != NullCollection {
+		return false, nil
+	}
+	reader.pos += 4
+	return true, nil
+}
+
+// readURL reads a URL encoded as its string form.
+func readURL(reader *Reader) (*url.URL, error) {
+	s, err := reader.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	return u, nil
+}
+
+// writeSyncMap writes the entries of m as a map of interface keys and values.
+// Keys and values must be built-in or registered types.
+func writeSyncMap(writer *Writer, m *sync.Map) error {
+	var entries []any
+	m.Range(func(key, value any) bool {
+		entries = append(entries, key, value)
+		return true
+	})
+
+	if err := writer.WriteCollectionHeader(len(entries) / 2); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := writeInterface(writer, reflect.ValueOf(&entry).Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSyncMap replaces the contents of m with the entries written by writeSyncMap.
+func readSyncMap(reader *Reader, m *sync.Map) error {
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil {
+		return err
+	}
+	m.Clear()
+	if isNull {
+		return nil
+	}
+
+	for range length {
+		var key, value any
+		if err = readInterface(reader, reflect.ValueOf(&key).Elem()); err != nil {
+			return err
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return fmt.Errorf("sync.Map key of type %T is not comparable", key)
+		}
+		if err = readInterface(reader, reflect.ValueOf(&value).Elem()); err != nil {
+			return err
+		}
+		m.Store(key, value)
+	}
+	return nil
+}
+
+// writeRing writes the values of a ring as a collection, starting at r.
+// Values must be built-in or registered types. A nil ring is written as null.
+func writeRing(writer *Writer, r *ring.Ring) error {
+	if r == nil {
+		writer.WriteNullCollectionHeader()
+		return nil
+	}
+
+	length := r.Len()
+	if err := writer.WriteCollectionHeader(length); err != nil {
+		return err
+	}
+	for i, p := 0, r; i < length; i, p = i+1, p.Next() {
+		if err := writeInterface(writer, reflect.ValueOf(&p.Value).Elem()); err != nil {
+			return withIndex(err, i)
+		}
+	}
+	return nil
+}
+
+// readRing reads a ring written by writeRing.
+func readRing(reader *Reader) (*ring.Ring, error) {
+	length, isNull, err := reader.ReadCollectionHeader()
+	if err != nil || isNull {
+		return nil, err
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if err = reader.checkLength(length, anyType); err != nil {
+		return nil, err
+	}
+	if err = reader.allocate(length, ringPtrType.Elem().Size()); err != nil {
+		return nil, err
+	}
+
+	r := ring.New(length)
+	for i, p := 0, r; i < length; i, p = i+1, p.Next() {
+		if err = readInterface(reader, reflect.ValueOf(&p.Value).Elem()); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
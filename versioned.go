@@ -0,0 +1,132 @@
+package memorypack
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// versionedOptions encodes versioned values with field names, so that adding
+// a field does not require a new version.
+var versionedOptions = Options{KeyedFields: true}
+
+var versionByType sync.Map // map[reflect.Type]versionInfo
+
+// versionInfo describes one registered version of a type.
+type versionInfo struct {
+	typ     reflect.Type
+	version byte
+	// prev is the previous version, and migrate a func(prev) (typ, error)
+	// converting from it; both are zero for the first version.
+	prev    reflect.Type
+	migrate reflect.Value
+}
+
+// RegisterVersion registers t as the given version of a type that evolves
+// over time, for use with SerializeVersioned and DeserializeVersioned.
+//
+// The first version is registered with a nil migrate. Each later version
+// passes a func(Prev) (T, error) converting a value of the previous version,
+// which must already be registered with the version before this one.
+func RegisterVersion(t reflect.Type, version byte, migrate any) error {
+	if t == nil || t.Kind() == reflect.Interface {
+		return fmt.Errorf("cannot register %v: versioned types must be concrete types", t)
+	}
+
+	info := versionInfo{typ: t, version: version}
+	if migrate != nil {
+		m := reflect.ValueOf(migrate)
+		mt := m.Type()
+		if mt.Kind() != reflect.Func || mt.NumIn() != 1 || mt.NumOut() != 2 || mt.Out(0) != t || mt.Out(1) != errorType {
+			return fmt.Errorf("migration to %s must be a func(Prev) (%s, error), got %s", t, t, mt)
+		}
+		prev, found := versionByType.Load(mt.In(0))
+		if !found {
+			return fmt.Errorf("cannot migrate to %s from %s: %s is not registered", t, mt.In(0), mt.In(0))
+		}
+		if p := prev.(versionInfo); version == 0 || p.version != version-1 {
+			return fmt.Errorf("version %d of %s cannot follow version %d of %s", version, t, p.version, p.typ)
+		}
+		info.prev, info.migrate = mt.In(0), m
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if existing, found := versionByType.Load(t); found {
+		return fmt.Errorf("type %s is already registered as version %d", t, existing.(versionInfo).version)
+	}
+	versionByType.Store(t, info)
+	return nil
+}
+
+// SerializeVersioned serializes a value of a type registered with
+// RegisterVersion, prefixed with its version byte.
+func SerializeVersioned(value any) ([]byte, error) {
+	t := reflect.TypeOf(value)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	info, found := versionByType.Load(t)
+	if !found {
+		return nil, fmt.Errorf("type %v is not registered with a version", t)
+	}
+
+	writer := NewWriterWithOptions(initialCapacityFor(value), versionedOptions)
+	writer.WriteByte(info.(versionInfo).version)
+	if err := encode(writer, value); err != nil {
+		return nil, err
+	}
+	return writer.GetBytes(), nil
+}
+
+// DeserializeVersioned deserializes data written by SerializeVersioned into
+// value, a pointer to a registered type. Data written with an earlier version
+// is decoded into that version and migrated forward one version at a time.
+func DeserializeVersioned(data []byte, value any) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("deserialize requires a non-nil pointer, got %T", value)
+	}
+	reader := NewReaderWithOptions(data, versionedOptions)
+	version, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	// Walk back from the target to the version the data was written with.
+	var chain []versionInfo
+	for t := v.Type().Elem(); ; {
+		registered, found := versionByType.Load(t)
+		if !found {
+			return fmt.Errorf("type %s is not registered with a version", t)
+		}
+		info := registered.(versionInfo)
+		if info.version == version {
+			break
+		}
+		if info.prev == nil {
+			return fmt.Errorf("data has version %d, which %s does not migrate from", version, v.Type().Elem())
+		}
+		chain = append(chain, info)
+		t = info.prev
+	}
+	if len(chain) == 0 {
+		return decode(reader, value)
+	}
+
+	current := reflect.New(chain[len(chain)-1].prev)
+	if err = decode(reader, current.Interface()); err != nil {
+		return err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		out := chain[i].migrate.Call([]reflect.Value{current.Elem()})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return fmt.Errorf("migrating to version %d of %s: %w", chain[i].version, chain[i].typ, err)
+		}
+		current = reflect.New(chain[i].typ)
+		current.Elem().Set(out[0])
+	}
+	v.Elem().Set(current.Elem())
+	return nil
+}
@@ -0,0 +1,119 @@
+package memorypack_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arisu-archive/memorypack-go"
+)
+
+type accountV1 struct {
+	Name string
+}
+
+type accountV2 struct {
+	First, Last string
+}
+
+type accountV3 struct {
+	First, Last string
+	Email       string
+}
+
+func init() {
+	if err := memorypack.RegisterVersion(reflect.TypeFor[accountV1](), 1, nil); err != nil {
+		panic(err)
+	}
+	if err := memorypack.RegisterVersion(reflect.TypeFor[accountV2](), 2, func(v accountV1) (accountV2, error) {
+		first, last, _ := strings.Cut(v.Name, " ")
+		return accountV2{First: first, Last: last}, nil
+	}); err != nil {
+		panic(err)
+	}
+	if err := memorypack.RegisterVersion(reflect.TypeFor[accountV3](), 3, func(v accountV2) (accountV3, error) {
+		if v.First == "" {
+			return accountV3{}, fmt.Errorf("account has no name")
+		}
+		return accountV3{First: v.First, Last: v.Last, Email: strings.ToLower(v.First) + "@example.com"}, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// TestVersioned tests migrating versioned data to the latest version.
+func TestVersioned(t *testing.T) {
+	t.Run("Upgrade", func(t *testing.T) {
+		data, err := memorypack.SerializeVersioned(&accountV1{Name: "Ada Lovelace"})
+		if err != nil {
+			t.Fatalf("SerializeVersioned failed: %v", err)
+		}
+		if data[0] != 1 {
+			t.Errorf("Expected version byte 1, got %d", data[0])
+		}
+
+		var result accountV3
+		if err = memorypack.DeserializeVersioned(data, &result); err != nil {
+			t.Fatalf("DeserializeVersioned failed: %v", err)
+		}
+		if want := (accountV3{First: "Ada", Last: "Lovelace", Email: "ada@example.com"}); result != want {
+			t.Errorf("Got %+v, want %+v", result, want)
+		}
+	})
+
+	t.Run("Latest", func(t *testing.T) {
+		original := accountV3{First: "Grace", Last: "Hopper", Email: "grace@navy.mil"}
+		data, err := memorypack.SerializeVersioned(&original)
+		if err != nil {
+			t.Fatalf("SerializeVersioned failed: %v", err)
+		}
+		var result accountV3
+		if err = memorypack.DeserializeVersioned(data, &result); err != nil {
+			t.Fatalf("DeserializeVersioned failed: %v", err)
+		}
+		if result != original {
+			t.Errorf("Got %+v, want %+v", result, original)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		newer, err := memorypack.SerializeVersioned(&accountV3{First: "x"})
+		if err != nil {
+			t.Fatalf("SerializeVersioned failed: %v", err)
+		}
+		var old accountV2
+		if err = memorypack.DeserializeVersioned(newer, &old); err == nil {
+			t.Error("Expected error decoding a newer version")
+		}
+
+		unnamed, err := memorypack.SerializeVersioned(&accountV1{})
+		if err != nil {
+			t.Fatalf("SerializeVersioned failed: %v", err)
+		}
+		var result accountV3
+		if err = memorypack.DeserializeVersioned(unnamed, &result); err == nil || !strings.Contains(err.Error(), "no name") {
+			t.Errorf("Expected migration error, got %v", err)
+		}
+
+		if _, err = memorypack.SerializeVersioned(&refNode{}); err == nil {
+			t.Error("Expected error for an unregistered type")
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		type skipped struct{ A int32 }
+		err := memorypack.RegisterVersion(reflect.TypeFor[skipped](), 5, func(v accountV3) (skipped, error) {
+			return skipped{}, nil
+		})
+		if err == nil {
+			t.Error("Expected error for a version that skips one")
+		}
+		if err = memorypack.RegisterVersion(reflect.TypeFor[skipped](), 4, func(v accountV3) string { return "" }); err == nil {
+			t.Error("Expected error for a migration with the wrong signature")
+		}
+		if err = memorypack.RegisterVersion(reflect.TypeFor[accountV1](), 1, nil); err == nil {
+			t.Error("Expected error registering a type twice")
+		}
+	})
+}
@@ -3,47 +3,180 @@ package memorypack
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
+	"sync"
 )
 
+// defaultInitialCapacity is the buffer size Serialize starts with for types
+// without a registered initial capacity.
+const defaultInitialCapacity = 128
+
+var initialCapacities sync.Map // map[reflect.Type]int
+
 // Serialize serializes any value into bytes.
 func Serialize(value any) ([]byte, error) {
-	writer := NewWriter(128)
+	return SerializeWithOptions(value, Options{})
+}
 
-	// Start with format version byte like C#
-	if formatter, ok := value.(Formatter); ok {
-		if err := formatter.Serialize(writer); err != nil {
-			return nil, fmt.Errorf("failed to serialize value: %w", err)
+// SerializeWithOptions serializes any value into bytes using the given options.
+func SerializeWithOptions(value any, opts Options) ([]byte, error) {
+	writer := NewWriterWithOptions(initialCapacityFor(value), opts)
+	if err := encode(writer, value); err != nil {
+		return nil, err
+	}
+	return writer.GetBytes(), nil
+}
+
+// RegisterInitialCapacity sets the buffer size Serialize starts with for
+// values of t, or pointers to them, to avoid regrowing the buffer for types
+// known to encode large.
+func RegisterInitialCapacity(t reflect.Type, capacity int) error {
+	if t == nil {
+		return fmt.Errorf("cannot register an initial capacity for nil")
+	}
+	if capacity <= 0 {
+		return fmt.Errorf("invalid initial capacity %d for %s", capacity, t)
+	}
+	initialCapacities.Store(t, capacity)
+	return nil
+}
+
+// initialCapacityFor returns the buffer size to serialize value with.
+func initialCapacityFor(value any) int {
+	t := reflect.TypeOf(value)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil {
+		if capacity, ok := initialCapacities.Load(t); ok {
+			return capacity.(int)
+		}
+	}
+	return defaultInitialCapacity
+}
+
+// SerializeValue writes v to w using the same encoding as a nested value.
+//
+// It writes no framing of its own, so callers composing several values must
+// write any headers they need, or use Serialize for whole messages.
+func SerializeValue(w *Writer, v reflect.Value) error {
+	if !v.IsValid() {
+		return fmt.Errorf("cannot serialize an invalid reflect.Value")
+	}
+	return writeValue(w, v)
+}
+
+// WriteValue writes v to w as a nested value. It lets the Formatter of a
+// generic type write values of its type parameters.
+func WriteValue[T any](w *Writer, v T) error {
+	return writeValue(w, reflect.ValueOf(&v).Elem())
+}
+
+// SerializeTo serializes any value and writes the result to w.
+//
+// A []byte value is written straight to w after its length header, without
+// first being copied into an intermediate buffer.
+func SerializeTo(w io.Writer, value any) error {
+	switch b := value.(type) {
+	case []byte:
+		return writeBytesTo(w, b)
+	case *[]byte:
+		if b != nil {
+			return writeBytesTo(w, *b)
+		}
+	}
+
+	data, err := Serialize(value)
+	if err != nil {
+		return err
+	}
+	return writeFull(w, data)
+}
+
+// writeBytesTo writes b to w in the encoding WriteBytes produces.
+func writeBytesTo(w io.Writer, b []byte) error {
+	if err := checkCollectionLength(len(b)); err != nil {
+		return err
+	}
+	var header [4]byte
+	length := int32(len(b))
+	if b == nil {
+		length = NullCollection
+	}
+	binary.LittleEndian.PutUint32(header[:], uint32(length))
+	if err := writeFull(w, header[:]); err != nil {
+		return err
+	}
+	return writeFull(w, b)
+}
+
+// writeFull writes all of data to w.
+func writeFull(w io.Writer, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	n, err := w.Write(data)
+	if err != nil {
+		return fmt.Errorf("write serialized value: %w", err)
+	}
+	if n != len(data) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+// encode serializes value into the writer.
+func encode(writer *Writer, value any) error {
+	if err := encodeValue(writer, value); err != nil {
+		return err
+	}
+	return writer.err
+}
+
+// encodeValue writes value, which encode has checked for write errors.
+func encodeValue(writer *Writer, value any) error {
+	if writer.options.TypeIDPrefix {
+		if err := writeTypeID(writer, value); err != nil {
+			return err
 		}
 	}
 	v := reflect.ValueOf(value)
+	if writer.options.TrackReferences {
+		registerRoot(writer, v)
+	}
 	// Handle nil pointers explicitly
 	if v.Kind() == reflect.Ptr && v.IsNil() {
 		writer.WriteByte(NullObject)
-	} else {
-		if v.Kind() == reflect.Ptr {
-			v = v.Elem()
-		}
-		if v.Kind() == reflect.Struct {
-			if err := serializeStruct(writer, v.Interface()); err != nil {
-				return nil, err
-			}
-		} else {
-			if err := writeValue(writer, v); err != nil {
-				return nil, err
-			}
+		return nil
+	}
+	if formatter, ok := value.(Formatter); ok {
+		if err := formatter.Serialize(writer); err != nil {
+			return fmt.Errorf("failed to serialize value: %w", err)
 		}
+		return nil
 	}
-
-	return writer.GetBytes(), nil
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return writeValue(writer, v)
 }
 
 // Writer handles serialization of data to a binary format.
 type Writer struct {
-	buffer []byte
-	pos    int
-	depth  int
+	buffer  []byte
+	pos     int
+	depth   int
+	options Options
+	refs    map[refKey]int
+
+	// filtered caches formatter data restricted by options.FieldFilter and
+	// TransientFields.
+	filtered map[reflect.Type]formatterData
+
+	// err is the first failure of a write method that cannot return one.
+	err error
 }
 
 // NewWriter creates a new MemoryPack writer with an optional initial capacity.
@@ -57,11 +190,60 @@ func NewWriter(initialCapacity int) *Writer {
 	}
 }
 
+// NewWriterWithOptions creates a new MemoryPack writer that encodes using the given options.
+func NewWriterWithOptions(initialCapacity int, opts Options) *Writer {
+	w := NewWriter(initialCapacity)
+	w.options = opts
+	return w
+}
+
+// fork returns a writer for encoding a nested payload into a separate buffer.
+// It shares the options, depth and tracked references of w.
+func (w *Writer) fork() *Writer {
+	if w.refs == nil && (w.options.TrackReferences || w.options.ShareSlices) {
+		w.refs = make(map[refKey]int)
+	}
+	sub := NewWriterWithOptions(64, w.options)
+	sub.depth = w.depth
+	sub.refs = w.refs
+	sub.filtered = w.filtered
+	return sub
+}
+
+// Reset discards the written bytes and tracked references so that the writer
+// can be reused, keeping its buffer.
+func (w *Writer) Reset() {
+	w.pos = 0
+	w.depth = 0
+	w.refs = nil
+	w.err = nil
+}
+
+// Err returns the first error from a write method without an error result,
+// such as WriteString given a string too long for its length header. Such
+// methods write nothing once they fail. Serialize and the other encoding
+// functions return this error, so Formatters need not check it.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// checkLength records an error and reports false if length does not fit in a
+// length header.
+func (w *Writer) checkLength(length int) bool {
+	if err := checkCollectionLength(length); err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		return false
+	}
+	return true
+}
+
 // CheckDepth increments the depth counter and checks for circular references.
 func (w *Writer) CheckDepth() error {
 	w.depth++
 	if w.depth > MaxDepth {
-		return fmt.Errorf("serialization depth exceeded %d, possible circular reference detected", MaxDepth)
+		return fmt.Errorf("serialization %w %d, possible circular reference detected", ErrDepthExceeded, MaxDepth)
 	}
 	return nil
 }
@@ -102,6 +284,11 @@ func (w *Writer) WriteByte(v byte) {
 	w.pos++
 }
 
+// WriteNull writes a null marker, for use by Formatters encoding optional values.
+func (w *Writer) WriteNull() {
+	w.WriteByte(NullObject)
+}
+
 // WriteBytes writes a byte slice to the buffer.
 func (w *Writer) WriteBytes(v []byte) {
 	if v == nil {
@@ -110,6 +297,10 @@ func (w *Writer) WriteBytes(v []byte) {
 		return
 	}
 
+	if !w.checkLength(len(v)) {
+		return
+	}
+
 	// Write the length
 	w.WriteInt32(int32(len(v)))
 
@@ -142,6 +333,18 @@ func (w *Writer) WriteInt64(v int64) {
 	w.pos += 8
 }
 
+// WriteVarint writes a zigzag-encoded signed varint to the buffer.
+func (w *Writer) WriteVarint(v int64) {
+	w.ensureCapacity(binary.MaxVarintLen64)
+	w.pos += binary.PutVarint(w.buffer[w.pos:], v)
+}
+
+// WriteUvarint writes an unsigned varint to the buffer.
+func (w *Writer) WriteUvarint(v uint64) {
+	w.ensureCapacity(binary.MaxVarintLen64)
+	w.pos += binary.PutUvarint(w.buffer[w.pos:], v)
+}
+
 // WriteFloat32 writes a float32 to the buffer.
 func (w *Writer) WriteFloat32(v float32) {
 	w.ensureCapacity(4)
@@ -167,12 +370,19 @@ func (w *Writer) WriteBool(v bool) {
 
 // WriteString writes a string to the buffer using MemoryPack format.
 func (w *Writer) WriteString(v string) {
+	if w.options.CompactStrings && w.writeShortString(v) {
+		return
+	}
 	if v == "" {
 		// Empty string - write zero collection header
 		w.WriteInt32(0)
 		return
 	}
 
+	if !w.checkLength(len(v)) {
+		return
+	}
+
 	// Convert string to UTF-8 bytes
 	utf8Bytes := []byte(v)
 	utf8ByteCount := len(utf8Bytes)
@@ -191,9 +401,38 @@ func (w *Writer) WriteString(v string) {
 	w.pos += utf8ByteCount
 }
 
+// WriteStringRaw writes a string as an int32 byte length followed by its UTF-8
+// bytes. It is four bytes shorter than WriteString, but is not compatible with
+// C# MemoryPack; read it with Reader.ReadStringRaw.
+func (w *Writer) WriteStringRaw(v string) {
+	if !w.checkLength(len(v)) {
+		return
+	}
+	w.ensureCapacity(4 + len(v))
+	w.WriteInt32(int32(len(v)))
+	copy(w.buffer[w.pos:], v)
+	w.pos += len(v)
+}
+
+// maxCollectionLength is the longest collection an int32 header can describe.
+var maxCollectionLength = math.MaxInt32
+
+// checkCollectionLength fails if length does not fit in a collection header.
+func checkCollectionLength(length int) error {
+	if length < 0 || length > maxCollectionLength {
+		return fmt.Errorf("collection length %d exceeds the maximum of %d", length, maxCollectionLength)
+	}
+	return nil
+}
+
 // WriteCollectionHeader writes a collection header (used for arrays, lists, etc).
-func (w *Writer) WriteCollectionHeader(length int) {
+// It fails without writing anything if length does not fit in the header.
+func (w *Writer) WriteCollectionHeader(length int) error {
+	if err := checkCollectionLength(length); err != nil {
+		return err
+	}
 	w.WriteInt32(int32(length))
+	return nil
 }
 
 // WriteNullCollectionHeader writes a null collection header.
@@ -213,3 +452,13 @@ func (w *Writer) WriteObjectHeader(memberCount int) error {
 	}
 	return nil
 }
+
+// WriteUnionHeader writes a union header for a value with the given tag.
+func (w *Writer) WriteUnionHeader(tag uint16) {
+	if tag < uint16(WideTag) {
+		w.WriteByte(byte(tag))
+		return
+	}
+	w.WriteByte(WideTag)
+	w.WriteInt16(int16(tag))
+}
@@ -1,8 +1,10 @@
 package memorypack_test
 
 import (
+	"io"
 	"math"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/arisu-archive/memorypack-go"
@@ -31,6 +33,87 @@ func TestWriter(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("StringRaw", func(t *testing.T) {
+		values := []string{"", "a", "héllo", "日本語テキスト"}
+
+		raw := memorypack.NewWriter(0)
+		for _, v := range values {
+			raw.WriteStringRaw(v)
+		}
+		reader := memorypack.NewReader(raw.GetBytes())
+		for _, want := range values {
+			if got, err := reader.ReadStringRaw(); err != nil || got != want {
+				t.Errorf("Expected %q, got %q, err: %v", want, got, err)
+			}
+		}
+
+		// A raw string saves the four-byte character count of WriteString.
+		for _, v := range values[1:] {
+			raw, standard := memorypack.NewWriter(0), memorypack.NewWriter(0)
+			raw.WriteStringRaw(v)
+			standard.WriteString(v)
+			if got, want := len(raw.GetBytes()), len(standard.GetBytes())-4; got != want {
+				t.Errorf("Expected %d bytes for raw %q, got %d", want, v, got)
+			}
+		}
+
+		if _, err := memorypack.NewReader([]byte{9, 0, 0, 0, 'a'}).ReadStringRaw(); err == nil {
+			t.Error("Expected error for a truncated raw string")
+		}
+	})
+
+	t.Run("CollectionLengthOverflow", func(t *testing.T) {
+		defer memorypack.SetMaxCollectionLength(memorypack.SetMaxCollectionLength(3))
+
+		writer := memorypack.NewWriter(0)
+		if err := writer.WriteCollectionHeader(4); err == nil {
+			t.Error("Expected error for a length beyond the header limit")
+		}
+		if n := len(writer.GetBytes()); n != 0 {
+			t.Errorf("Expected nothing written, got %d bytes", n)
+		}
+
+		overflowing := []any{
+			[]int32{1, 2, 3, 4},
+			[4]string{},
+			map[int32]bool{1: true, 2: true, 3: true, 4: true},
+			[]byte{1, 2, 3, 4},
+			"abcd",
+		}
+		for _, value := range overflowing {
+			if _, err := memorypack.Serialize(value); err == nil || !strings.Contains(err.Error(), "exceeds the maximum") {
+				t.Errorf("Expected overflow error for %T, got %v", value, err)
+			}
+		}
+		if _, err := memorypack.SerializeWithOptions(&[]bool{true, false, true}, memorypack.Options{PackBools: true}); err == nil {
+			t.Error("Expected overflow error for packed bools")
+		}
+		if _, err := memorypack.SerializeWithOptions(&[]int64{1, 2, 3, 4}, memorypack.Options{DeltaInts: true}); err == nil {
+			t.Error("Expected overflow error for delta ints")
+		}
+
+		// Writers without an error result record the failure instead.
+		for name, write := range map[string]func(w *memorypack.Writer){
+			"WriteBytes":     func(w *memorypack.Writer) { w.WriteBytes([]byte{1, 2, 3, 4}) },
+			"WriteString":    func(w *memorypack.Writer) { w.WriteString("abcd") },
+			"WriteStringRaw": func(w *memorypack.Writer) { w.WriteStringRaw("abcd") },
+		} {
+			writer := memorypack.NewWriter(0)
+			write(writer)
+			if writer.Err() == nil || len(writer.GetBytes()) != 0 {
+				t.Errorf("%s: expected an error and nothing written, got %v and %d bytes", name, writer.Err(), len(writer.GetBytes()))
+			}
+		}
+		if _, err := memorypack.Serialize(&CustomFormat{StrValue: "abcd"}); err == nil || !strings.Contains(err.Error(), "exceeds the maximum") {
+			t.Errorf("Expected overflow error from a Formatter, got %v", err)
+		}
+		if err := memorypack.SerializeTo(io.Discard, []byte{1, 2, 3, 4}); err == nil {
+			t.Error("Expected overflow error from SerializeTo")
+		}
+
+		testRoundTrip(t, []int32{1, 2, 3})
+	})
 }
 
 // TestReader tests the Reader class directly.
@@ -61,6 +144,140 @@ func TestReader(t *testing.T) {
 			t.Error("Expected error when reading beyond buffer, got nil")
 		}
 	})
+
+	t.Run("Discard", func(t *testing.T) {
+		reader := memorypack.NewReader([]byte{1, 2, 3, 4, 5})
+
+		n, err := reader.Discard(3)
+		if err != nil || n != 3 {
+			t.Errorf("Expected to discard 3 bytes, got %d, err: %v", n, err)
+		}
+		if b, err := reader.ReadByte(); err != nil || b != 4 {
+			t.Errorf("Expected 4 after discarding, got %d, err: %v", b, err)
+		}
+
+		n, err = reader.Discard(5)
+		if err == nil || n != 1 {
+			t.Errorf("Expected error after discarding 1 byte, got %d, err: %v", n, err)
+		}
+		if _, err = reader.ReadByte(); err == nil {
+			t.Error("Expected reader to be exhausted, got nil error")
+		}
+	})
+
+	t.Run("SkipValue", func(t *testing.T) {
+		type Point struct {
+			X, Y int16
+		}
+		values := []any{
+			[]int64{1, -2, 3},
+			map[string][]int16{"a": {1}, "bc": nil},
+			map[int32]string{-1: "x"},
+			[2]string{"left", ""},
+			[]byte{0xFF, 0},
+			&Point{X: 1, Y: -1},
+			[]*Point{nil, {X: 2}},
+			[]any{int32(1), "two"},
+		}
+		for _, opts := range []memorypack.Options{{}, {TaggedPrimitives: true, CompactStrings: true, CompactMapKeys: true}} {
+			for _, value := range values {
+				data, err := memorypack.SerializeWithOptions(value, opts)
+				if err != nil {
+					t.Fatalf("Serialize(%T) failed: %v", value, err)
+				}
+				reader := memorypack.NewReaderWithOptions(append(data, 0xAB), opts)
+				if err = memorypack.SkipValue(reader, reflect.TypeOf(value)); err != nil {
+					t.Errorf("SkipValue(%T) with %+v failed: %v", value, opts, err)
+					continue
+				}
+				if b, err := reader.ReadByte(); err != nil || b != 0xAB {
+					t.Errorf("SkipValue(%T) with %+v stopped at %x, err: %v", value, opts, b, err)
+				}
+			}
+		}
+	})
+
+	t.Run("SubReader", func(t *testing.T) {
+		inner := memorypack.NewWriter(32)
+		if err := inner.WriteObjectHeader(2); err != nil {
+			t.Fatalf("WriteObjectHeader failed: %v", err)
+		}
+		inner.WriteString("ping")
+		inner.WriteInt32(7)
+
+		outer := memorypack.NewWriter(64)
+		outer.WriteInt16(1)
+		outer.WriteBytes(inner.GetBytes())
+		outer.WriteString("trailer")
+
+		reader := memorypack.NewReader(outer.GetBytes())
+		if kind, err := reader.ReadInt16(); err != nil || kind != 1 {
+			t.Fatalf("Expected kind 1, got %d, err: %v", kind, err)
+		}
+		sub, err := reader.ReadSubReader()
+		if err != nil {
+			t.Fatalf("ReadSubReader failed: %v", err)
+		}
+
+		if count, _, err := sub.ReadObjectHeader(); err != nil || count != 2 {
+			t.Fatalf("Expected 2 members, got %d, err: %v", count, err)
+		}
+		if name, err := sub.ReadString(); err != nil || name != "ping" {
+			t.Errorf("Expected ping, got %q, err: %v", name, err)
+		}
+		if seq, err := sub.ReadInt32(); err != nil || seq != 7 {
+			t.Errorf("Expected 7, got %d, err: %v", seq, err)
+		}
+		if _, err = sub.ReadByte(); err == nil {
+			t.Error("Expected sub-reader to stop at the frame end")
+		}
+
+		if trailer, err := reader.ReadString(); err != nil || trailer != "trailer" {
+			t.Errorf("Expected trailer, got %q, err: %v", trailer, err)
+		}
+
+		truncated := memorypack.NewReader([]byte{10, 0, 0, 0, 1, 2})
+		if _, err = truncated.ReadSubReader(); err == nil {
+			t.Error("Expected error for truncated frame")
+		}
+	})
+
+	t.Run("PeekMemberCount", func(t *testing.T) {
+		type Pair struct {
+			Key   string
+			Value int32
+		}
+		data, err := memorypack.Serialize(&Pair{Key: "k", Value: 1})
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if count, isNull, err := memorypack.PeekMemberCount(data); err != nil || isNull || count != 2 {
+			t.Errorf("Expected 2 members, got %d (null %v), err: %v", count, isNull, err)
+		}
+
+		// 249 is the widest count an object header can hold.
+		if count, isNull, err := memorypack.PeekMemberCount([]byte{249}); err != nil || isNull || count != 249 {
+			t.Errorf("Expected 249 members, got %d (null %v), err: %v", count, isNull, err)
+		}
+
+		var nilPair *Pair
+		data, err = memorypack.Serialize(&nilPair)
+		if err != nil {
+			t.Fatalf("Serialize failed: %v", err)
+		}
+		if count, isNull, err := memorypack.PeekMemberCount(data); err != nil || !isNull || count != 0 {
+			t.Errorf("Expected null object, got %d (null %v), err: %v", count, isNull, err)
+		}
+
+		for _, header := range []byte{memorypack.WideTag, memorypack.Reserved5} {
+			if _, _, err = memorypack.PeekMemberCount([]byte{header, 0, 0}); err == nil {
+				t.Errorf("Expected error for reserved header %d", header)
+			}
+		}
+		if _, _, err = memorypack.PeekMemberCount(nil); err == nil {
+			t.Error("Expected error for empty data")
+		}
+	})
 }
 
 // TestCustomTypes tests serialization of custom structs with tags.
@@ -199,6 +416,38 @@ func BenchmarkDeserialization(b *testing.B) {
 	b.SetBytes(int64(len(data)))
 }
 
+type sampleBatch struct {
+	Samples []int64
+}
+
+type sizedSampleBatch sampleBatch
+
+// BenchmarkInitialCapacity compares serializing a large type with and without
+// a registered initial capacity.
+func BenchmarkInitialCapacity(b *testing.B) {
+	samples := make([]int64, 4096)
+	if err := memorypack.RegisterInitialCapacity(reflect.TypeFor[sizedSampleBatch](), 8*len(samples)+16); err != nil {
+		b.Fatalf("RegisterInitialCapacity failed: %v", err)
+	}
+
+	for _, bc := range []struct {
+		name  string
+		value any
+	}{
+		{"Default", &sampleBatch{Samples: samples}},
+		{"Registered", &sizedSampleBatch{Samples: samples}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := memorypack.Serialize(bc.value); err != nil {
+					b.Fatalf("Serialize failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // TestSpecialNumericCases tests edge cases with numeric values.
 func TestSpecialNumericCases(t *testing.T) {
 	t.Run("FloatSpecialValues", func(t *testing.T) {